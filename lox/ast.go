@@ -14,6 +14,16 @@ type ExprVisitor interface {
 	visitAssignExpr(*Assign)
 	visitLogicalExpr(*Logical)
 	visitCallExpr(*Call)
+	visitGetExpr(*Get)
+	visitSetExpr(*Set)
+	visitThisExpr(*This)
+	visitSuperExpr(*Super)
+	visitListLiteralExpr(*ListLiteral)
+	visitIndexExpr(*Index)
+	visitIndexSetExpr(*IndexSet)
+	visitSliceExpr(*Slice)
+	visitMapLiteralExpr(*MapLiteral)
+	visitLambdaExpr(*Lambda)
 }
 
 type Binary struct {
@@ -41,6 +51,8 @@ func (u *Unary) Accept(v ExprVisitor) {
 
 type Literal struct {
 	Value any
+	// The token the value was parsed from
+	token Token
 }
 
 func (l *Literal) Accept(v ExprVisitor) {
@@ -57,6 +69,9 @@ func (g *Grouping) Accept(v ExprVisitor) {
 
 type Variable struct {
 	Name Token
+	// Where the variable is stored, as determined by the
+	// Resolver, or nil if it is global
+	local *location
 }
 
 func (va *Variable) Accept(v ExprVisitor) {
@@ -66,6 +81,7 @@ func (va *Variable) Accept(v ExprVisitor) {
 type Assign struct {
 	Name Token
 	Value Expr
+	local *location
 }
 
 func (a *Assign) Accept(v ExprVisitor) {
@@ -92,6 +108,110 @@ func (c *Call) Accept(v ExprVisitor) {
 	v.visitCallExpr(c)
 }
 
+type Get struct {
+	object Expr
+	name Token
+}
+
+func (g *Get) Accept(v ExprVisitor) {
+	v.visitGetExpr(g)
+}
+
+type Set struct {
+	object Expr
+	name Token
+	value Expr
+}
+
+func (s *Set) Accept(v ExprVisitor) {
+	v.visitSetExpr(s)
+}
+
+type This struct {
+	keyword Token
+	local *location
+}
+
+func (t *This) Accept(v ExprVisitor) {
+	v.visitThisExpr(t)
+}
+
+type Super struct {
+	keyword Token
+	method Token
+	local *location
+}
+
+func (s *Super) Accept(v ExprVisitor) {
+	v.visitSuperExpr(s)
+}
+
+type ListLiteral struct {
+	bracket Token
+	elements []Expr
+}
+
+func (l *ListLiteral) Accept(v ExprVisitor) {
+	v.visitListLiteralExpr(l)
+}
+
+type Index struct {
+	object Expr
+	bracket Token
+	index Expr
+}
+
+func (i *Index) Accept(v ExprVisitor) {
+	v.visitIndexExpr(i)
+}
+
+type IndexSet struct {
+	object Expr
+	bracket Token
+	index Expr
+	value Expr
+}
+
+func (i *IndexSet) Accept(v ExprVisitor) {
+	v.visitIndexSetExpr(i)
+}
+
+// Slice is an expression like list[start:end], where
+// either bound may be nil
+type Slice struct {
+	object Expr
+	bracket Token
+	start Expr
+	end Expr
+}
+
+func (s *Slice) Accept(v ExprVisitor) {
+	v.visitSliceExpr(s)
+}
+
+// MapLiteral holds the entries of a map literal
+// as parallel slices of keys and values
+type MapLiteral struct {
+	brace Token
+	keys []Expr
+	values []Expr
+}
+
+func (m *MapLiteral) Accept(v ExprVisitor) {
+	v.visitMapLiteralExpr(m)
+}
+
+// Lambda is an anonymous function expression, either 'fun (a) { ... }'
+// or '(a) => expr'. The name of its declaration is left empty.
+type Lambda struct {
+	keyword Token
+	decl *FunctionStmt
+}
+
+func (l *Lambda) Accept(v ExprVisitor) {
+	v.visitLambdaExpr(l)
+}
+
 type Stmt interface {
 	Accept(StmtVisitor)
 }
@@ -120,6 +240,24 @@ type StmtVisitor interface {
 
 	// Return statement
 	visitReturnStmt(*ReturnStmt)
+
+	// Class declaration statement
+	visitClassStmt(*ClassStmt)
+
+	// Break statement
+	visitBreakStmt(*BreakStmt)
+
+	// Continue statement
+	visitContinueStmt(*ContinueStmt)
+
+	// For-in loop statement
+	visitForInStmt(*ForInStmt)
+
+	// Throw statement
+	visitThrowStmt(*ThrowStmt)
+
+	// Try statement
+	visitTryStmt(*TryStmt)
 }
 
 type ExpressionStmt struct {
@@ -148,6 +286,9 @@ func (vs *VarStmt) Accept(v StmtVisitor) {
 }
 
 type BlockStmt struct {
+	// The '{' token, or for blocks made by the parser or
+	// optimiser, a token of the code they stand in for
+	brace Token
 	statements []Stmt
 }
 
@@ -178,6 +319,9 @@ func (fs *FunctionStmt) Accept(v StmtVisitor) {
 type WhileStmt struct {
 	expr Expr
 	body Stmt
+	// Evaluated after each iteration, including those ended
+	// by 'continue'. Only set for desugared 'for' loops
+	increment Expr
 }
 
 func (ws *WhileStmt) Accept(v StmtVisitor) {
@@ -192,3 +336,65 @@ type ReturnStmt struct {
 func (rs *ReturnStmt) Accept(v StmtVisitor) {
 	v.visitReturnStmt(rs)
 }
+
+type ClassStmt struct {
+	name Token
+	superclass *Variable
+	methods []*FunctionStmt
+}
+
+func (cs *ClassStmt) Accept(v StmtVisitor) {
+	v.visitClassStmt(cs)
+}
+
+type BreakStmt struct {
+	keyword Token
+}
+
+func (bs *BreakStmt) Accept(v StmtVisitor) {
+	v.visitBreakStmt(bs)
+}
+
+type ContinueStmt struct {
+	keyword Token
+}
+
+func (cs *ContinueStmt) Accept(v StmtVisitor) {
+	v.visitContinueStmt(cs)
+}
+
+// ForInStmt is a loop of the form 'for (var name in iterable) body'
+type ForInStmt struct {
+	keyword Token
+	name Token
+	iterable Expr
+	body Stmt
+}
+
+func (fs *ForInStmt) Accept(v StmtVisitor) {
+	v.visitForInStmt(fs)
+}
+
+type ThrowStmt struct {
+	keyword Token
+	value Expr
+}
+
+func (ts *ThrowStmt) Accept(v StmtVisitor) {
+	v.visitThrowStmt(ts)
+}
+
+// TryStmt has a catch clause, a finally clause or both. Without a
+// catch clause catchBody is nil, and without a finally clause
+// finallyBody is nil.
+type TryStmt struct {
+	keyword Token
+	body []Stmt
+	catchName Token
+	catchBody []Stmt
+	finallyBody []Stmt
+}
+
+func (ts *TryStmt) Accept(v StmtVisitor) {
+	v.visitTryStmt(ts)
+}
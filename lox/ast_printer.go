@@ -2,6 +2,7 @@ package lox
 
 import (
 	"fmt"
+	"strings"
 )
 
 type ASTPrinter struct{
@@ -13,6 +14,142 @@ func (p *ASTPrinter) PrintAST(e Expr) {
 	fmt.Println(p.result)
 }
 
+// PrintProgram returns the statements as S-expressions, one per
+// line, with the statements nested inside them indented
+func (p *ASTPrinter) PrintProgram(statements []Stmt) string {
+	var lines []string
+	for _, stmt := range statements {
+		lines = append(lines, p.stmt(stmt))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (p *ASTPrinter) stmt(stmt Stmt) string {
+	stmt.Accept(p)
+	return p.result
+}
+
+func (p *ASTPrinter) expr(expr Expr) string {
+	expr.Accept(p)
+	return p.result
+}
+
+// block formats a statement with a header, followed by
+// the statements nested in it
+func (p *ASTPrinter) block(header string, statements ...Stmt) string {
+	var lines []string
+	for _, stmt := range statements {
+		lines = append(lines, p.stmt(stmt))
+	}
+	return nest(header, lines)
+}
+
+func nest(header string, lines []string) string {
+	res := "(" + header
+	for _, line := range lines {
+		res += "\n  " + strings.ReplaceAll(line, "\n", "\n  ")
+	}
+	return res + ")"
+}
+
+func (p *ASTPrinter) visitExpressionStmt(s *ExpressionStmt) {
+	p.result = p.parenthesise("expr", s.Expr)
+}
+
+func (p *ASTPrinter) visitPrintStmt(s *PrintStmt) {
+	p.result = p.parenthesise("print", s.Expr)
+}
+
+func (p *ASTPrinter) visitVarStmt(s *VarStmt) {
+	if s.Initialiser == nil {
+		p.result = "(var-decl " + s.Name.Lexeme + ")"
+		return
+	}
+	p.result = p.parenthesise("var-decl " + s.Name.Lexeme, s.Initialiser)
+}
+
+func (p *ASTPrinter) visitBlockStmt(s *BlockStmt) {
+	p.result = p.block("block", s.statements...)
+}
+
+func (p *ASTPrinter) visitIfStmt(s *IfStmt) {
+	branches := []Stmt{s.thenBranch}
+	if s.elseBranch != nil {
+		branches = append(branches, s.elseBranch)
+	}
+	p.result = p.block("if " + p.expr(s.expr), branches...)
+}
+
+func (p *ASTPrinter) visitWhileStmt(s *WhileStmt) {
+	header := "while " + p.expr(s.expr)
+	if s.increment != nil {
+		header += " " + p.expr(s.increment)
+	}
+	p.result = p.block(header, s.body)
+}
+
+func (p *ASTPrinter) visitForInStmt(s *ForInStmt) {
+	p.result = p.block("for-in " + s.name.Lexeme + " " + p.expr(s.iterable), s.body)
+}
+
+func (p *ASTPrinter) visitFunctionStmt(s *FunctionStmt) {
+	p.result = p.function("fun " + s.name.Lexeme, s)
+}
+
+func (p *ASTPrinter) function(header string, decl *FunctionStmt) string {
+	var params []string
+	for _, param := range decl.params {
+		params = append(params, param.Lexeme)
+	}
+	return p.block(header + " (" + strings.Join(params, " ") + ")", decl.body...)
+}
+
+func (p *ASTPrinter) visitReturnStmt(s *ReturnStmt) {
+	if s.value == nil {
+		p.result = "(return)"
+		return
+	}
+	p.result = p.parenthesise("return", s.value)
+}
+
+func (p *ASTPrinter) visitClassStmt(s *ClassStmt) {
+	header := "class " + s.name.Lexeme
+	if s.superclass != nil {
+		header += " < " + s.superclass.Name.Lexeme
+	}
+	var methods []Stmt
+	for _, method := range s.methods {
+		methods = append(methods, method)
+	}
+	p.result = p.block(header, methods...)
+}
+
+func (p *ASTPrinter) visitBreakStmt(s *BreakStmt) {
+	p.result = "(break)"
+}
+
+func (p *ASTPrinter) visitContinueStmt(s *ContinueStmt) {
+	p.result = "(continue)"
+}
+
+func (p *ASTPrinter) visitThrowStmt(s *ThrowStmt) {
+	p.result = p.parenthesise("throw", s.value)
+}
+
+func (p *ASTPrinter) visitTryStmt(s *TryStmt) {
+	var lines []string
+	for _, stmt := range s.body {
+		lines = append(lines, p.stmt(stmt))
+	}
+	if s.catchBody != nil {
+		lines = append(lines, p.block("catch " + s.catchName.Lexeme, s.catchBody...))
+	}
+	if s.finallyBody != nil {
+		lines = append(lines, p.block("finally", s.finallyBody...))
+	}
+	p.result = nest("try", lines)
+}
+
 func (p *ASTPrinter) visitBinaryExpr(b *Binary) {
 	p.result = p.parenthesise(b.Op.Lexeme, b.Left, b.Right)
 }
@@ -22,11 +159,8 @@ func (p *ASTPrinter) visitUnaryExpr(u *Unary) {
 }
 
 func (p *ASTPrinter) visitLiteralExpr(l *Literal) {
-	if l.Value == nil {
-		p.result = "nil"
-	} else {
-		p.result = fmt.Sprint(l.Value)
-	}
+	// Quote strings so that they can be told apart from numbers
+	p.result = repr(l.Value)
 }
 
 func (p *ASTPrinter) visitGroupingExpr(g *Grouping) {
@@ -34,11 +168,11 @@ func (p *ASTPrinter) visitGroupingExpr(g *Grouping) {
 }
 
 func (p *ASTPrinter) visitVariableExpr(v *Variable) {
-	p.result = p.parenthesise("var", v)
+	p.result = "(var " + v.Name.Lexeme + ")"
 }
 
 func (p *ASTPrinter) visitAssignExpr(a *Assign) {
-	p.result = p.parenthesise("assign", a)
+	p.result = p.parenthesise("assign " + a.Name.Lexeme, a.Value)
 }
 
 func (p *ASTPrinter) visitLogicalExpr(l *Logical) {
@@ -50,6 +184,57 @@ func (p *ASTPrinter) visitCallExpr(l *Call) {
 	p.result = p.parenthesise(p.result, l.arguments...)
 }
 
+func (p *ASTPrinter) visitGetExpr(g *Get) {
+	p.result = p.parenthesise("get " + g.name.Lexeme, g.object)
+}
+
+func (p *ASTPrinter) visitSetExpr(s *Set) {
+	p.result = p.parenthesise("set " + s.name.Lexeme, s.object, s.value)
+}
+
+func (p *ASTPrinter) visitThisExpr(t *This) {
+	p.result = "this"
+}
+
+func (p *ASTPrinter) visitSuperExpr(s *Super) {
+	p.result = "(super " + s.method.Lexeme + ")"
+}
+
+func (p *ASTPrinter) visitListLiteralExpr(l *ListLiteral) {
+	p.result = p.parenthesise("list", l.elements...)
+}
+
+func (p *ASTPrinter) visitIndexExpr(i *Index) {
+	p.result = p.parenthesise("index", i.object, i.index)
+}
+
+func (p *ASTPrinter) visitIndexSetExpr(i *IndexSet) {
+	p.result = p.parenthesise("index-set", i.object, i.index, i.value)
+}
+
+func (p *ASTPrinter) visitSliceExpr(s *Slice) {
+	start, end := s.start, s.end
+	if start == nil {
+		start = &Literal{Value: nil}
+	}
+	if end == nil {
+		end = &Literal{Value: nil}
+	}
+	p.result = p.parenthesise("slice", s.object, start, end)
+}
+
+func (p *ASTPrinter) visitMapLiteralExpr(m *MapLiteral) {
+	var entries []Expr
+	for idx := range m.keys {
+		entries = append(entries, m.keys[idx], m.values[idx])
+	}
+	p.result = p.parenthesise("map", entries...)
+}
+
+func (p *ASTPrinter) visitLambdaExpr(l *Lambda) {
+	p.result = p.function("fun", l.decl)
+}
+
 func (p *ASTPrinter) parenthesise(name string, exprs ...Expr) string {
 	res := "(" + name
 	for _, e := range exprs {
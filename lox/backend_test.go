@@ -0,0 +1,200 @@
+package lox
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// backends are the backends that tests and benchmarks run on
+var backends = []struct {
+	name    string
+	backend Backend
+}{
+	{"TreeWalk", TreeWalk},
+	{"Bytecode", Bytecode},
+}
+
+// runBackend runs source on a new VM with the backend, returning what it
+// printed and the report of any error. A Go panic that reaches the host
+// is reported as well. Scripts can call boom(), a buggy native which
+// writes to a nil map.
+func runBackend(t *testing.T, backend Backend, source string) (stdout string, stderr string) {
+	t.Helper()
+	var out, errs bytes.Buffer
+	vm := NewVM(&out, &errs, WithBackend(backend))
+	vm.DefineNative("boom", 0, func(args []any) (any, error) {
+		var m map[string]any
+		m["x"] = 1
+		return nil, nil
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(&errs, "panic:", r)
+		}
+		stdout, stderr = out.String(), errs.String()
+	}()
+	if _, err := vm.EvalNamed("test.lox", source); err != nil {
+		ReportError(&errs, err)
+	}
+	return
+}
+
+// TestBackendParity runs each script on both backends, which
+// should print the same output and report the same errors
+func TestBackendParity(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// The expected output
+		want string
+		// The expected error message, if the script fails
+		err string
+	}{
+		{
+			name: "closures",
+			source: `
+				fun counter() {
+					var n = 0;
+					return fun() { n = n + 1; return n; };
+				}
+				var a = counter();
+				var b = counter();
+				a(); a();
+				print a();
+				print b();
+				var fns = [];
+				for (var i = 0; i < 3; i = i + 1) {
+					var j = i;
+					fns.push(fun() { return j; });
+				}
+				print fns.map(fun(f) { return f(); });`,
+			want: "3\n1\n[0, 1, 2]\n",
+		},
+		{
+			name: "classes and super",
+			source: `
+				class A {
+					init(name) { this.name = name; }
+					greet() { return "A " + this.name; }
+				}
+				class B < A {
+					greet() { return super.greet() + " and B"; }
+				}
+				var b = B("x");
+				print b.greet();
+				var greet = b.greet;
+				b.name = "y";
+				print greet();
+				print b;
+				print B;`,
+			want: "A x and B\nA y and B\nB instance\nB\n",
+		},
+		{
+			name: "finally with break, continue and return",
+			source: `
+				for (var i = 0; i < 3; i = i + 1) {
+					try {
+						if (i == 0) continue;
+						if (i == 2) break;
+						print "body " + "1";
+					} finally {
+						print "finally";
+					}
+				}
+				fun f() {
+					try { return "try"; } finally { print "leaving"; }
+				}
+				print f();
+				fun g() {
+					try { return "try"; } finally { return "finally"; }
+				}
+				print g();`,
+			want: "finally\nbody 1\nfinally\nfinally\nleaving\ntry\nfinally\n",
+		},
+		{
+			name: "exceptions",
+			source: `
+				fun fail() { throw Error("bad"); }
+				try { fail(); } catch (e) { print e.message; }
+				try { nil.x; } catch (e) { print e.message; }
+				try {
+					try { throw "inner"; } finally { print "cleanup"; }
+				} catch (e) { print e; }`,
+			want: "bad\nOnly instances have properties\ncleanup\ninner\n",
+		},
+		{
+			name: "for-in",
+			source: `
+				for (var x in [1, 2]) print x;
+				for (var c in "hé") print c;
+				var m = {"a": 1};
+				for (var k in m) print k;`,
+			want: "1\n2\nh\né\na\n",
+		},
+		{
+			name: "runtime error",
+			source: `
+				fun f(x) { return x + 1; }
+				print "before";
+				f("a");`,
+			want: "before\n",
+			err:  "Operands must be two numbers or two strings",
+		},
+		{
+			name: "uncaught exception",
+			source: `
+				fun f() { throw Error("oops"); }
+				f();`,
+			err: "Uncaught exception: oops",
+		},
+		{
+			name: "set property on nil",
+			source: `
+				fun f() { print "called"; return 1; }
+				nil.x = f();`,
+			want: "called\n",
+			err:  "Only instances have fields",
+		},
+		{
+			name: "closure declared before a shadowing local",
+			source: `
+				var a = "global";
+				{
+					fun show() { print a; }
+					show();
+					var a = "block";
+					show();
+				}`,
+			want: "global\nglobal\n",
+		},
+		{
+			name: "Go panic through finally",
+			source: `
+				fun f() {
+					try { boom(); } finally { return "swallowed"; }
+				}
+				print f();`,
+			err: "panic: assignment to entry in nil map",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			treeOut, treeErr := runBackend(t, TreeWalk, tt.source)
+			byteOut, byteErr := runBackend(t, Bytecode, tt.source)
+			if treeOut != tt.want {
+				t.Errorf("TreeWalk printed %q, want %q", treeOut, tt.want)
+			}
+			if tt.err == "" && treeErr != "" || !strings.Contains(treeErr, tt.err) {
+				t.Errorf("TreeWalk reported %q, want %q", treeErr, tt.err)
+			}
+			if byteOut != treeOut {
+				t.Errorf("Bytecode printed %q, TreeWalk printed %q", byteOut, treeOut)
+			}
+			if byteErr != treeErr {
+				t.Errorf("Bytecode reported %q, TreeWalk reported %q", byteErr, treeErr)
+			}
+		})
+	}
+}
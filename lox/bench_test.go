@@ -0,0 +1,65 @@
+package lox
+
+import (
+	"io"
+	"testing"
+)
+
+// benchmark defines the functions in setup on a VM for each backend,
+// then times running source
+func benchmark(b *testing.B, setup string, source string) {
+	for _, bb := range backends {
+		b.Run(bb.name, func(b *testing.B) {
+			vm := NewVM(io.Discard, io.Discard, WithBackend(bb.backend))
+			if _, err := vm.Eval(setup); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if _, err := vm.Eval(source); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmark(b, `
+		fun fib(n) {
+			if (n < 2) return n;
+			return fib(n - 1) + fib(n - 2);
+		}`,
+		`fib(20);`)
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmark(b, `
+		fun loop() {
+			var total = 0;
+			for (var i = 0; i < 100000; i = i + 1) {
+				var x = i * 2;
+				total = total + x;
+			}
+			return total;
+		}`,
+		`loop();`)
+}
+
+func BenchmarkCall(b *testing.B) {
+	benchmark(b, `
+		fun add(a, b) { return a + b; }
+		class Counter {
+			init() { this.n = 0; }
+			inc() { this.n = this.n + 1; return this.n; }
+		}
+		fun calls() {
+			var counter = Counter();
+			var total = 0;
+			for (var i = 0; i < 20000; i = i + 1) {
+				total = add(total, counter.inc());
+			}
+			return total;
+		}`,
+		`calls();`)
+}
@@ -0,0 +1,44 @@
+package lox
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// host is what native functions need from the VM they run in
+type host struct {
+	// Natives that reach outside the VM check these before acting
+	caps Capability
+	// Natives which can build values much larger than their
+	// arguments check that they fit in this first
+	budget *budget
+	// Generates the numbers of math.random
+	rng *rand.Rand
+	// The standard streams, other than standard output, which
+	// is written by print. stdin is nil if there's no input.
+	stdin  *bufio.Reader
+	stderr io.Writer
+}
+
+// defineBuiltins adds the native functions and modules available to
+// every Lox program, using define to create each global
+func defineBuiltins(defineGlobal func(name string, value any), h *host) {
+	define := func(name string, arity int, fn NativeFn) {
+		defineGlobal(name, NewNativeFunction(name, arity, fn))
+	}
+
+	// Seconds since the Unix epoch
+	define("clock", 0, func(args []any) (any, error) {
+		if err := h.caps.require("clock", CapClock); err != nil {
+			return nil, err
+		}
+		return float64(time.Now().UnixNano()) / float64(time.Second), nil
+	})
+
+	defineGlobal("os", osModule(h))
+	defineGlobal("strings", stringsModule(h))
+	defineGlobal("math", mathModule(h))
+	defineGlobal("io", ioModule(h))
+}
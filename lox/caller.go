@@ -0,0 +1,15 @@
+package lox
+
+// caller is implemented by each backend, so that the runtime types
+// they share, such as lists, can call back into Lox code
+type caller interface {
+	// callValue calls callee with args, reporting errors at token.
+	// An exception thrown by the call is returned as the error.
+	callValue(callee any, token Token, args []any) (any, error)
+	// invoke calls the named method of an instance, reporting false
+	// if object isn't an instance or has no such method
+	invoke(object any, token Token, name string, args []any) (any, bool, error)
+	// allocate counts size towards the allocation limit, stopping
+	// the script at token if it is exceeded
+	allocate(token Token, size int)
+}
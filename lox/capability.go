@@ -0,0 +1,64 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capability is a set of things outside the VM which scripts can
+// use through native functions. Sets are combined with |.
+type Capability uint
+
+const (
+	// CapFSRead allows reading files and listing directories
+	CapFSRead Capability = 1 << iota
+	// CapFSWrite allows creating and changing files
+	CapFSWrite
+	// CapEnv allows reading environment variables
+	CapEnv
+	// CapClock allows reading the time
+	CapClock
+	// CapExec allows running other programs
+	CapExec
+	// CapStdio allows reading standard input and writing to
+	// standard error
+	CapStdio
+
+	// AllCapabilities is every capability, as given to
+	// scripts run by the glox command
+	AllCapabilities = CapFSRead | CapFSWrite | CapEnv | CapClock | CapExec | CapStdio
+)
+
+var capabilityNames = []string{"fs-read", "fs-write", "env", "clock", "exec", "stdio"}
+
+// String names the capabilities in the set, e.g. "fs-read|env"
+func (c Capability) String() string {
+	var names []string
+	for idx, name := range capabilityNames {
+		if c&(1<<idx) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// WithCapabilities sets what scripts run by the VM may do outside of
+// it. The default is no capabilities, so that untrusted scripts can
+// only compute and print.
+func WithCapabilities(caps Capability) Option {
+	return func(vm *VM) {
+		vm.capabilities = caps
+	}
+}
+
+// require returns the error that the native function fn raises
+// when the set lacks any of need
+func (c Capability) require(fn string, need Capability) error {
+	if c&need == need {
+		return nil
+	}
+	return fmt.Errorf("%s needs the %s capability, which this script doesn't have", fn, need&^c)
+}
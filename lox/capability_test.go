@@ -0,0 +1,67 @@
+package lox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCapabilities calls each native which needs a capability from a
+// VM without it, where the call should raise an error that the script
+// can catch, and from a VM with every capability, where it should work
+func TestCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(input, []byte("hello\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.txt")
+	// The test binary can be run without doing anything
+	program := os.Args[0]
+
+	tests := []struct {
+		name string
+		call string
+		// The error raised without the capability
+		err string
+	}{
+		{"clock", `clock()`, "clock needs the clock capability"},
+		{"os.getenv", `os.getenv("HOME")`, "os.getenv needs the env capability"},
+		{"os.exec", `os.exec("` + program + `", "-test.run=^$")`, "os.exec needs the exec capability"},
+		{"io.readFile", `io.readFile("` + input + `")`, "io.readFile needs the fs-read capability"},
+		{"io.writeFile", `io.writeFile("` + output + `", "x")`, "io.writeFile needs the fs-write capability"},
+		{"io.readLine", `io.readLine()`, "io.readLine needs the stdio capability"},
+	}
+	for _, tt := range tests {
+		source := `
+			try {
+				` + tt.call + `;
+				print "allowed";
+			} catch (e) {
+				print e.message;
+			}`
+		for _, b := range backends {
+			t.Run(tt.name+"/"+b.name, func(t *testing.T) {
+				var stdout, stderr bytes.Buffer
+				vm := NewVM(&stdout, &stderr, WithBackend(b.backend))
+				if _, err := vm.Eval(source); err != nil {
+					t.Fatalf("without capabilities: %v", err)
+				}
+				want := tt.err + ", which this script doesn't have\n"
+				if stdout.String() != want {
+					t.Errorf("without capabilities printed %q, want %q", stdout.String(), want)
+				}
+
+				stdout.Reset()
+				vm = NewVM(&stdout, &stderr, WithBackend(b.backend), WithCapabilities(AllCapabilities))
+				if _, err := vm.Eval(source); err != nil {
+					t.Fatalf("with all capabilities: %v", err)
+				}
+				if stdout.String() != "allowed\n" {
+					t.Errorf("with all capabilities printed %q", stdout.String())
+				}
+			})
+		}
+	}
+}
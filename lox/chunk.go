@@ -0,0 +1,65 @@
+package lox
+
+import "math"
+
+// position is the location in the source that an
+// instruction was compiled from
+type position struct {
+	line   int
+	column int
+	offset int
+	length int
+}
+
+func tokenPosition(token Token) position {
+	return position{line: token.Line, column: token.Column, offset: token.Offset, length: token.Length}
+}
+
+// Chunk is a sequence of bytecode, along with the constants it refers
+// to and the source position of each byte
+type Chunk struct {
+	code      []byte
+	constants []any
+	positions []position
+	source    *Source
+}
+
+func (c *Chunk) write(b byte, pos position) {
+	c.code = append(c.code, b)
+	c.positions = append(c.positions, pos)
+}
+
+// addConstant returns the index of value in the constant pool,
+// reusing an existing entry for identical numbers and strings
+func (c *Chunk) addConstant(value any) int {
+	for idx, constant := range c.constants {
+		switch v := value.(type) {
+		case float64:
+			// Compare bits so that 0 and -0 are kept apart
+			if f, ok := constant.(float64); ok && math.Float64bits(f) == math.Float64bits(v) {
+				return idx
+			}
+		case string:
+			if constant == value {
+				return idx
+			}
+		}
+	}
+	c.constants = append(c.constants, value)
+	return len(c.constants) - 1
+}
+
+// token reconstructs a token for the instruction at offset, so that
+// runtime errors can be reported in the same way as by the Interpreter
+func (c *Chunk) token(offset int) Token {
+	pos := c.positions[offset]
+	token := Token{Line: pos.line, Column: pos.column, Offset: pos.offset, Length: pos.length, source: c.source}
+	if c.source != nil && pos.offset+pos.length <= len(c.source.Text) {
+		token.Lexeme = c.source.Text[pos.offset : pos.offset+pos.length]
+	}
+	return token
+}
+
+func (c *Chunk) readShort(offset int) int {
+	return int(c.code[offset])<<8 | int(c.code[offset+1])
+}
@@ -0,0 +1,819 @@
+package lox
+
+import "math"
+
+// Compiler translates statements which have been checked by the
+// Resolver to bytecode for the Machine. Each function is compiled
+// to its own Chunk.
+type Compiler struct {
+	current *funcCompiler
+	// The most recently compiled token. Instructions are
+	// attributed to it, for reporting runtime errors.
+	token  Token
+	errors Diagnostics
+}
+
+// funcCompiler holds the state of a function being compiled
+type funcCompiler struct {
+	enclosing  *funcCompiler
+	function   *funcProto
+	kind       FunctionType
+	locals     []local
+	upvalues   []upvalueRef
+	scopeDepth int
+	loop       *loopState
+	try        *tryState
+}
+
+// local is a variable in a stack slot of the current function.
+// Slot 0 holds 'this' in methods, and the function itself otherwise.
+type local struct {
+	name     string
+	depth    int
+	captured bool
+}
+
+type upvalueRef struct {
+	index   byte
+	isLocal bool
+}
+
+// loopState is the innermost loop being compiled, which 'break'
+// and 'continue' statements jump out of
+type loopState struct {
+	enclosing *loopState
+	// Locals and handlers in scope outside of the loop body
+	localCount int
+	try        *tryState
+	breaks     []int
+	continues  []int
+}
+
+// tryState is an exception handler which is active in the code
+// being compiled. Jumping out of it has to remove the handler, and
+// run the finally block if it has one.
+type tryState struct {
+	enclosing   *tryState
+	localCount  int
+	loop        *loopState
+	finallyBody []Stmt
+}
+
+func NewCompiler() *Compiler {
+	return &Compiler{}
+}
+
+// Compile compiles a program to a function which runs it, and
+// returns the value of the final statement if it is an expression
+func (c *Compiler) Compile(statements []Stmt) (*funcProto, error) {
+	c.beginFunction(NONE, "")
+	for idx, stmt := range statements {
+		if expr, ok := stmt.(*ExpressionStmt); ok && idx == len(statements)-1 {
+			c.compileExpr(expr.Expr)
+			c.emit(OP_RETURN)
+			break
+		}
+		c.compileStmt(stmt)
+	}
+	function := c.endFunction()
+	return function, c.errors.errorOrNil()
+}
+
+func (c *Compiler) error(msg string) {
+	c.errors = append(c.errors, ErrorOnToken(CodeCompile, c.token, msg))
+}
+
+// at attributes the instructions that follow to token
+func (c *Compiler) at(token Token) {
+	c.token = token
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return c.current.function.chunk
+}
+
+func (c *Compiler) emit(op OpCode, operands ...byte) {
+	pos := tokenPosition(c.token)
+	c.chunk().write(byte(op), pos)
+	for _, b := range operands {
+		c.chunk().write(b, pos)
+	}
+}
+
+func (c *Compiler) emitShort(op OpCode, operand int) {
+	c.emit(op, byte(operand>>8), byte(operand))
+}
+
+func (c *Compiler) makeConstant(value any) int {
+	idx := c.chunk().addConstant(value)
+	if idx > math.MaxUint16 {
+		c.error("Too many constants in one chunk")
+		return 0
+	}
+	return idx
+}
+
+// emitJump emits a jump with a placeholder offset, returning
+// the position of the offset for patchJump
+func (c *Compiler) emitJump(op OpCode, operands ...byte) int {
+	c.emit(op, append(operands, 0xff, 0xff)...)
+	return len(c.chunk().code) - 2
+}
+
+// patchJump makes the jump at offset land on the next instruction
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.chunk().code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.error("Too much code to jump over")
+	}
+	c.chunk().code[offset] = byte(jump >> 8)
+	c.chunk().code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) patchJumps(offsets []int) {
+	for _, offset := range offsets {
+		c.patchJump(offset)
+	}
+}
+
+func (c *Compiler) emitLoop(start int) {
+	// Skip back over the loop instruction itself too
+	offset := len(c.chunk().code) - start + 3
+	if offset > math.MaxUint16 {
+		c.error("Loop body too large")
+	}
+	c.emitShort(OP_LOOP, offset)
+}
+
+func (c *Compiler) beginFunction(kind FunctionType, name string) {
+	fc := &funcCompiler{
+		enclosing: c.current,
+		function:  &funcProto{name: name, chunk: &Chunk{}},
+		kind:      kind,
+	}
+	slot := ""
+	if kind == METHOD || kind == INITIALISER {
+		slot = "this"
+	}
+	fc.locals = append(fc.locals, local{name: slot})
+	c.current = fc
+}
+
+func (c *Compiler) endFunction() *funcProto {
+	c.emitReturn()
+	function := c.current.function
+	function.upvalueCount = len(c.current.upvalues)
+	function.chunk.source = c.token.source
+	c.current = c.current.enclosing
+	return function
+}
+
+// emitReturn returns from the function with the default return value
+func (c *Compiler) emitReturn() {
+	if c.current.kind == INITIALISER {
+		c.emit(OP_GET_LOCAL, 0)
+	} else {
+		c.emit(OP_NIL)
+	}
+	c.emit(OP_RETURN)
+}
+
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	fc := c.current
+	fc.scopeDepth--
+	for len(fc.locals) > 0 && fc.locals[len(fc.locals)-1].depth > fc.scopeDepth {
+		if fc.locals[len(fc.locals)-1].captured {
+			c.emit(OP_CLOSE_UPVALUE)
+		} else {
+			c.emit(OP_POP)
+		}
+		fc.locals = fc.locals[:len(fc.locals)-1]
+	}
+}
+
+// addLocal binds name to the value on top of the stack
+func (c *Compiler) addLocal(name string) {
+	fc := c.current
+	if len(fc.locals) > math.MaxUint8 {
+		c.error("Too many local variables in function")
+		return
+	}
+	fc.locals = append(fc.locals, local{name: name, depth: fc.scopeDepth})
+}
+
+// defineVariable binds name to the value on top of the stack,
+// as a global at the top level and a local otherwise
+func (c *Compiler) defineVariable(name Token) {
+	if c.current.scopeDepth > 0 {
+		c.addLocal(name.Lexeme)
+		return
+	}
+	c.at(name)
+	c.emitShort(OP_DEFINE_GLOBAL, c.makeConstant(name.Lexeme))
+}
+
+func resolveLocal(fc *funcCompiler, name string) int {
+	for idx := len(fc.locals) - 1; idx >= 0; idx-- {
+		if fc.locals[idx].name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// resolveUpvalue looks for name in the enclosing functions, adding
+// an upvalue to each function between there and fc
+func (c *Compiler) resolveUpvalue(fc *funcCompiler, name string) int {
+	if fc.enclosing == nil {
+		return -1
+	}
+	if idx := resolveLocal(fc.enclosing, name); idx != -1 {
+		fc.enclosing.locals[idx].captured = true
+		return c.addUpvalue(fc, idx, true)
+	}
+	if idx := c.resolveUpvalue(fc.enclosing, name); idx != -1 {
+		return c.addUpvalue(fc, idx, false)
+	}
+	return -1
+}
+
+func (c *Compiler) addUpvalue(fc *funcCompiler, index int, isLocal bool) int {
+	for idx, uv := range fc.upvalues {
+		if int(uv.index) == index && uv.isLocal == isLocal {
+			return idx
+		}
+	}
+	if len(fc.upvalues) > math.MaxUint8 {
+		c.error("Too many closure variables in function")
+		return 0
+	}
+	fc.upvalues = append(fc.upvalues, upvalueRef{index: byte(index), isLocal: isLocal})
+	return len(fc.upvalues) - 1
+}
+
+// variable emits the instruction to read the named variable,
+// or to assign the value on top of the stack to it if set is true
+func (c *Compiler) variable(name Token, set bool) {
+	c.at(name)
+	if idx := resolveLocal(c.current, name.Lexeme); idx != -1 {
+		c.emit(pick(set, OP_SET_LOCAL, OP_GET_LOCAL), byte(idx))
+	} else if idx := c.resolveUpvalue(c.current, name.Lexeme); idx != -1 {
+		c.emit(pick(set, OP_SET_UPVALUE, OP_GET_UPVALUE), byte(idx))
+	} else {
+		c.emitShort(pick(set, OP_SET_GLOBAL, OP_GET_GLOBAL), c.makeConstant(name.Lexeme))
+	}
+}
+
+func pick(cond bool, ifTrue OpCode, ifFalse OpCode) OpCode {
+	if cond {
+		return ifTrue
+	}
+	return ifFalse
+}
+
+// syntheticToken names a variable which is implicitly in scope
+func (c *Compiler) syntheticToken(lexeme string) Token {
+	token := c.token
+	token.Lexeme = lexeme
+	return token
+}
+
+func (c *Compiler) compileStmt(stmt Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *Compiler) compileExpr(expr Expr) {
+	expr.Accept(c)
+}
+
+func (c *Compiler) statements(statements []Stmt) {
+	for _, stmt := range statements {
+		c.compileStmt(stmt)
+	}
+}
+
+func (c *Compiler) block(statements []Stmt) {
+	c.beginScope()
+	c.statements(statements)
+	c.endScope()
+}
+
+// function compiles decl, and emits the instruction to create
+// a closure of it
+func (c *Compiler) function(decl *FunctionStmt, kind FunctionType) {
+	token := c.token
+	c.beginFunction(kind, decl.name.Lexeme)
+	c.beginScope()
+	for _, param := range decl.params {
+		c.at(param)
+		c.addLocal(param.Lexeme)
+	}
+	c.current.function.arity = len(decl.params)
+	c.statements(decl.body)
+
+	upvalues := c.current.upvalues
+	function := c.endFunction()
+	c.at(token)
+	c.emitShort(OP_CLOSURE, c.makeConstant(function))
+	for _, uv := range upvalues {
+		isLocal := byte(0)
+		if uv.isLocal {
+			isLocal = 1
+		}
+		c.chunk().write(isLocal, tokenPosition(c.token))
+		c.chunk().write(uv.index, tokenPosition(c.token))
+	}
+}
+
+// exitTries emits the code to leave each handler between the current
+// point and stop, running their finally blocks on the way. If keepTop
+// is true the value on top of the stack is kept, e.g. for 'return'.
+func (c *Compiler) exitTries(stop *tryState, keepTop bool) {
+	fc := c.current
+	keep := byte(0)
+	if keepTop {
+		keep = 1
+	}
+	for t := fc.try; t != stop; t = t.enclosing {
+		c.emit(OP_UNWIND, byte(t.localCount), keep)
+		c.emit(OP_END_TRY)
+		if t.finallyBody != nil {
+			c.inlineFinally(t, keepTop)
+		}
+	}
+}
+
+// inlineFinally compiles a copy of the finally block of t, for
+// a jump out of its try statement
+func (c *Compiler) inlineFinally(t *tryState, keepTop bool) {
+	fc := c.current
+	locals, depth, try, loop := fc.locals, fc.scopeDepth, fc.try, fc.loop
+	// Copied, as the slots above t.localCount are reused
+	fc.locals = append([]local{}, locals[:t.localCount]...)
+	fc.try, fc.loop = t.enclosing, t.loop
+
+	c.beginScope()
+	if keepTop {
+		c.addLocal("")
+	}
+	c.block(t.finallyBody)
+
+	fc.locals, fc.scopeDepth, fc.try, fc.loop = locals, depth, try, loop
+}
+
+func (c *Compiler) beginLoop() *loopState {
+	fc := c.current
+	fc.loop = &loopState{enclosing: fc.loop, localCount: len(fc.locals), try: fc.try}
+	return fc.loop
+}
+
+// endLoop makes the loop's 'break' statements jump here
+func (c *Compiler) endLoop(loop *loopState) {
+	c.patchJumps(loop.breaks)
+	c.current.loop = loop.enclosing
+}
+
+// visitExpressionStmt implements StmtVisitor.
+func (c *Compiler) visitExpressionStmt(stmt *ExpressionStmt) {
+	c.compileExpr(stmt.Expr)
+	c.emit(OP_POP)
+}
+
+// visitPrintStmt implements StmtVisitor.
+func (c *Compiler) visitPrintStmt(stmt *PrintStmt) {
+	c.compileExpr(stmt.Expr)
+	c.emit(OP_PRINT)
+}
+
+// visitVarStmt implements StmtVisitor.
+func (c *Compiler) visitVarStmt(stmt *VarStmt) {
+	if stmt.Initialiser != nil {
+		c.compileExpr(stmt.Initialiser)
+	} else {
+		c.at(stmt.Name)
+		c.emit(OP_NIL)
+	}
+	c.defineVariable(stmt.Name)
+}
+
+// visitBlockStmt implements StmtVisitor.
+func (c *Compiler) visitBlockStmt(stmt *BlockStmt) {
+	c.block(stmt.statements)
+}
+
+// visitIfStmt implements StmtVisitor.
+func (c *Compiler) visitIfStmt(stmt *IfStmt) {
+	c.compileExpr(stmt.expr)
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+	c.compileStmt(stmt.thenBranch)
+	elseJump := c.emitJump(OP_JUMP)
+	c.patchJump(thenJump)
+	c.emit(OP_POP)
+	if stmt.elseBranch != nil {
+		c.compileStmt(stmt.elseBranch)
+	}
+	c.patchJump(elseJump)
+}
+
+// visitWhileStmt implements StmtVisitor.
+func (c *Compiler) visitWhileStmt(stmt *WhileStmt) {
+	start := len(c.chunk().code)
+	c.compileExpr(stmt.expr)
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+
+	loop := c.beginLoop()
+	c.compileStmt(stmt.body)
+	c.patchJumps(loop.continues)
+	if stmt.increment != nil {
+		c.compileExpr(stmt.increment)
+		c.emit(OP_POP)
+	}
+	c.emitLoop(start)
+
+	c.patchJump(exitJump)
+	c.emit(OP_POP)
+	c.endLoop(loop)
+}
+
+// visitForInStmt implements StmtVisitor.
+func (c *Compiler) visitForInStmt(stmt *ForInStmt) {
+	c.compileExpr(stmt.iterable)
+	c.at(stmt.keyword)
+	c.emit(OP_ITER)
+	// The iterator is kept in a hidden local
+	c.beginScope()
+	c.addLocal("")
+
+	start := len(c.chunk().code)
+	c.at(stmt.keyword)
+	exitJump := c.emitJump(OP_FOR_ITER)
+	loop := c.beginLoop()
+	// The loop variable is bound in a fresh scope each iteration
+	c.beginScope()
+	c.addLocal(stmt.name.Lexeme)
+	c.compileStmt(stmt.body)
+	c.endScope()
+	c.patchJumps(loop.continues)
+	c.emitLoop(start)
+
+	c.patchJump(exitJump)
+	c.endLoop(loop)
+	c.endScope()
+}
+
+// visitBreakStmt implements StmtVisitor.
+func (c *Compiler) visitBreakStmt(stmt *BreakStmt) {
+	c.at(stmt.keyword)
+	loop := c.current.loop
+	c.exitTries(loop.try, false)
+	c.emit(OP_UNWIND, byte(loop.localCount), 0)
+	loop.breaks = append(loop.breaks, c.emitJump(OP_JUMP))
+}
+
+// visitContinueStmt implements StmtVisitor.
+func (c *Compiler) visitContinueStmt(stmt *ContinueStmt) {
+	c.at(stmt.keyword)
+	loop := c.current.loop
+	c.exitTries(loop.try, false)
+	c.emit(OP_UNWIND, byte(loop.localCount), 0)
+	loop.continues = append(loop.continues, c.emitJump(OP_JUMP))
+}
+
+// visitReturnStmt implements StmtVisitor.
+func (c *Compiler) visitReturnStmt(stmt *ReturnStmt) {
+	// An early 'return;' in an initialiser still produces the instance
+	if stmt.value != nil && c.current.kind != INITIALISER {
+		c.compileExpr(stmt.value)
+	} else {
+		c.at(stmt.keyword)
+		if c.current.kind == INITIALISER {
+			c.emit(OP_GET_LOCAL, 0)
+		} else {
+			c.emit(OP_NIL)
+		}
+	}
+	c.at(stmt.keyword)
+	c.exitTries(nil, true)
+	c.emit(OP_RETURN)
+}
+
+// visitFunctionStmt implements StmtVisitor.
+func (c *Compiler) visitFunctionStmt(stmt *FunctionStmt) {
+	c.at(stmt.name)
+	if c.current.scopeDepth > 0 {
+		// Bound before the body is compiled, so
+		// that the function can call itself
+		c.addLocal(stmt.name.Lexeme)
+		c.function(stmt, FUNCTION)
+		return
+	}
+	c.function(stmt, FUNCTION)
+	c.defineVariable(stmt.name)
+}
+
+// visitClassStmt implements StmtVisitor.
+func (c *Compiler) visitClassStmt(stmt *ClassStmt) {
+	if stmt.superclass != nil {
+		// Checked before the class is defined, as the
+		// Interpreter does
+		c.variable(stmt.superclass.Name, false)
+		c.emit(OP_CHECK_SUPERCLASS)
+		c.emit(OP_POP)
+	}
+
+	c.at(stmt.name)
+	c.emitShort(OP_CLASS, c.makeConstant(stmt.name.Lexeme))
+	c.defineVariable(stmt.name)
+
+	if stmt.superclass != nil {
+		// Methods of a subclass close over a local 'super'
+		c.beginScope()
+		c.variable(stmt.superclass.Name, false)
+		c.addLocal("super")
+		c.variable(stmt.name, false)
+		c.emit(OP_INHERIT)
+	}
+
+	c.variable(stmt.name, false)
+	for _, method := range stmt.methods {
+		kind := METHOD
+		if method.name.Lexeme == "init" {
+			kind = INITIALISER
+		}
+		c.at(method.name)
+		c.function(method, kind)
+		c.emitShort(OP_METHOD, c.makeConstant(method.name.Lexeme))
+	}
+	c.emit(OP_POP)
+
+	if stmt.superclass != nil {
+		c.endScope()
+	}
+}
+
+// visitThrowStmt implements StmtVisitor.
+func (c *Compiler) visitThrowStmt(stmt *ThrowStmt) {
+	c.compileExpr(stmt.value)
+	c.at(stmt.keyword)
+	c.emit(OP_THROW)
+}
+
+// visitTryStmt implements StmtVisitor.
+//
+// A finally clause is compiled twice: inline after the try statement,
+// and as a handler which runs it and then rethrows the exception. A
+// catch clause is a handler nested inside that.
+func (c *Compiler) visitTryStmt(stmt *TryStmt) {
+	fc := c.current
+	c.at(stmt.keyword)
+
+	var finallyHandler int
+	if stmt.finallyBody != nil {
+		finallyHandler = c.emitJump(OP_TRY, handlerFinally)
+		fc.try = &tryState{enclosing: fc.try, localCount: len(fc.locals), loop: fc.loop, finallyBody: stmt.finallyBody}
+	}
+
+	if stmt.catchBody != nil {
+		catchHandler := c.emitJump(OP_TRY, handlerCatch)
+		fc.try = &tryState{enclosing: fc.try, localCount: len(fc.locals), loop: fc.loop}
+		c.block(stmt.body)
+		fc.try = fc.try.enclosing
+		c.at(stmt.keyword)
+		c.emit(OP_END_TRY)
+		endJump := c.emitJump(OP_JUMP)
+
+		// The Machine pushes the caught value
+		c.patchJump(catchHandler)
+		c.beginScope()
+		c.addLocal(stmt.catchName.Lexeme)
+		c.statements(stmt.catchBody)
+		c.endScope()
+		c.patchJump(endJump)
+	} else {
+		c.block(stmt.body)
+	}
+
+	if stmt.finallyBody != nil {
+		fc.try = fc.try.enclosing
+		c.at(stmt.keyword)
+		c.emit(OP_END_TRY)
+		c.block(stmt.finallyBody)
+		endJump := c.emitJump(OP_JUMP)
+
+		// The Machine pushes the exception in progress, which
+		// is rethrown after the finally block has run
+		c.patchJump(finallyHandler)
+		c.beginScope()
+		c.addLocal("")
+		c.block(stmt.finallyBody)
+		c.at(stmt.keyword)
+		c.emit(OP_RETHROW)
+		// Nothing to pop, as rethrowing leaves the scope
+		fc.locals = fc.locals[:len(fc.locals)-1]
+		fc.scopeDepth--
+		c.patchJump(endJump)
+	}
+}
+
+// visitBinaryExpr implements ExprVisitor.
+func (c *Compiler) visitBinaryExpr(expr *Binary) {
+	c.compileExpr(expr.Left)
+	c.compileExpr(expr.Right)
+	c.at(expr.Op)
+	switch expr.Op.Type {
+	case GREATER:
+		c.emit(OP_GREATER)
+	case GREATER_EQUAL:
+		c.emit(OP_GREATER_EQUAL)
+	case LESS:
+		c.emit(OP_LESS)
+	case LESS_EQUAL:
+		c.emit(OP_LESS_EQUAL)
+	case BANG_EQUAL:
+		c.emit(OP_NOT_EQUAL)
+	case EQUAL_EQUAL:
+		c.emit(OP_EQUAL)
+	case MINUS:
+		c.emit(OP_SUBTRACT)
+	case PLUS:
+		c.emit(OP_ADD)
+	case SLASH:
+		c.emit(OP_DIVIDE)
+	case STAR:
+		c.emit(OP_MULTIPLY)
+	default:
+		// As in the Interpreter, unknown operators produce nil
+		c.emit(OP_POP)
+		c.emit(OP_POP)
+		c.emit(OP_NIL)
+	}
+}
+
+// visitLogicalExpr implements ExprVisitor.
+func (c *Compiler) visitLogicalExpr(expr *Logical) {
+	c.compileExpr(expr.left)
+	c.at(expr.op)
+	if expr.op.Type == OR {
+		elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+		endJump := c.emitJump(OP_JUMP)
+		c.patchJump(elseJump)
+		c.emit(OP_POP)
+		c.compileExpr(expr.right)
+		c.patchJump(endJump)
+		return
+	}
+	endJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+	c.compileExpr(expr.right)
+	c.patchJump(endJump)
+}
+
+// visitLiteralExpr implements ExprVisitor.
+func (c *Compiler) visitLiteralExpr(expr *Literal) {
+	c.at(expr.token)
+	switch expr.Value {
+	case nil:
+		c.emit(OP_NIL)
+	case true:
+		c.emit(OP_TRUE)
+	case false:
+		c.emit(OP_FALSE)
+	default:
+		c.emitShort(OP_CONSTANT, c.makeConstant(expr.Value))
+	}
+}
+
+// visitGroupingExpr implements ExprVisitor.
+func (c *Compiler) visitGroupingExpr(expr *Grouping) {
+	c.compileExpr(expr.Expr)
+}
+
+// visitUnaryExpr implements ExprVisitor.
+func (c *Compiler) visitUnaryExpr(expr *Unary) {
+	c.compileExpr(expr.Right)
+	c.at(expr.Op)
+	switch expr.Op.Type {
+	case MINUS:
+		c.emit(OP_NEGATE)
+	case BANG:
+		c.emit(OP_NOT)
+	}
+}
+
+// visitVariableExpr implements ExprVisitor.
+func (c *Compiler) visitVariableExpr(expr *Variable) {
+	c.variable(expr.Name, false)
+}
+
+// visitAssignExpr implements ExprVisitor.
+func (c *Compiler) visitAssignExpr(expr *Assign) {
+	c.compileExpr(expr.Value)
+	c.variable(expr.Name, true)
+}
+
+// visitCallExpr implements ExprVisitor.
+func (c *Compiler) visitCallExpr(expr *Call) {
+	c.compileExpr(expr.callee)
+	for _, arg := range expr.arguments {
+		c.compileExpr(arg)
+	}
+	c.at(expr.paren)
+	c.emit(OP_CALL, byte(len(expr.arguments)))
+}
+
+// visitGetExpr implements ExprVisitor.
+func (c *Compiler) visitGetExpr(expr *Get) {
+	c.compileExpr(expr.object)
+	c.at(expr.name)
+	c.emitShort(OP_GET_PROPERTY, c.makeConstant(expr.name.Lexeme))
+}
+
+// visitSetExpr implements ExprVisitor.
+func (c *Compiler) visitSetExpr(expr *Set) {
+	c.compileExpr(expr.object)
+	c.compileExpr(expr.value)
+	c.at(expr.name)
+	c.emitShort(OP_SET_PROPERTY, c.makeConstant(expr.name.Lexeme))
+}
+
+// visitThisExpr implements ExprVisitor.
+func (c *Compiler) visitThisExpr(expr *This) {
+	c.variable(expr.keyword, false)
+}
+
+// visitSuperExpr implements ExprVisitor.
+func (c *Compiler) visitSuperExpr(expr *Super) {
+	c.at(expr.keyword)
+	c.variable(c.syntheticToken("this"), false)
+	c.variable(c.syntheticToken("super"), false)
+	c.at(expr.method)
+	c.emitShort(OP_GET_SUPER, c.makeConstant(expr.method.Lexeme))
+}
+
+// visitListLiteralExpr implements ExprVisitor.
+func (c *Compiler) visitListLiteralExpr(expr *ListLiteral) {
+	for _, element := range expr.elements {
+		c.compileExpr(element)
+	}
+	c.at(expr.bracket)
+	if len(expr.elements) > math.MaxUint16 {
+		c.error("Too many elements in list literal")
+	}
+	c.emitShort(OP_LIST, len(expr.elements))
+}
+
+// visitMapLiteralExpr implements ExprVisitor.
+func (c *Compiler) visitMapLiteralExpr(expr *MapLiteral) {
+	for idx := range expr.keys {
+		c.compileExpr(expr.keys[idx])
+		c.compileExpr(expr.values[idx])
+	}
+	c.at(expr.brace)
+	if len(expr.keys) > math.MaxUint16 {
+		c.error("Too many entries in map literal")
+	}
+	c.emitShort(OP_MAP, len(expr.keys))
+}
+
+// visitIndexExpr implements ExprVisitor.
+func (c *Compiler) visitIndexExpr(expr *Index) {
+	c.compileExpr(expr.object)
+	c.compileExpr(expr.index)
+	c.at(expr.bracket)
+	c.emit(OP_INDEX)
+}
+
+// visitIndexSetExpr implements ExprVisitor.
+func (c *Compiler) visitIndexSetExpr(expr *IndexSet) {
+	c.compileExpr(expr.object)
+	c.compileExpr(expr.index)
+	c.compileExpr(expr.value)
+	c.at(expr.bracket)
+	c.emit(OP_SET_INDEX)
+}
+
+// visitSliceExpr implements ExprVisitor.
+func (c *Compiler) visitSliceExpr(expr *Slice) {
+	c.compileExpr(expr.object)
+	for _, bound := range []Expr{expr.start, expr.end} {
+		if bound != nil {
+			c.compileExpr(bound)
+		} else {
+			c.emit(OP_NIL)
+		}
+	}
+	c.at(expr.bracket)
+	c.emit(OP_SLICE)
+}
+
+// visitLambdaExpr implements ExprVisitor.
+func (c *Compiler) visitLambdaExpr(expr *Lambda) {
+	c.at(expr.keyword)
+	c.function(expr.decl, FUNCTION)
+}
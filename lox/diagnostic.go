@@ -0,0 +1,184 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityNote
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityNote:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+// Diagnostic codes group errors by the stage that found them
+const (
+	CodeScan    = "E100"
+	CodeSyntax  = "E200"
+	CodeResolve = "E300"
+	CodeRuntime = "E400"
+	CodeCompile = "E500"
+)
+
+// Source is a named piece of Lox source code, such as a script
+// file or a line entered at the prompt
+type Source struct {
+	Name string
+	Text string
+}
+
+// Line returns the text of the given 1-based line, without
+// its line ending
+func (s *Source) Line(line int) string {
+	lines := strings.Split(s.Text, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// Span is a region of a Source. Column is 1-based and counts
+// characters rather than bytes, while Offset and Length are in bytes.
+type Span struct {
+	Source *Source
+	Offset int
+	Line   int
+	Column int
+	Length int
+}
+
+func tokenSpan(token Token) Span {
+	return Span{Source: token.source, Offset: token.Offset, Line: token.Line, Column: token.Column, Length: token.Length}
+}
+
+// Diagnostic describes a problem found in a Lox program at
+// any stage, from scanning through to running it
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Span     Span
+	Message  string
+	Notes    []string
+	// For runtime errors, the calls that led to the error
+	Trace []StackFrame
+	// The underlying error, e.g. a RuntimeError
+	cause error
+}
+
+func (d *Diagnostic) Error() string {
+	name := "<unknown>"
+	if d.Span.Source != nil {
+		name = d.Span.Source.Name
+	}
+	return fmt.Sprintf("%s:%d:%d: %s[%s]: %s", name, d.Span.Line, d.Span.Column, d.Severity, d.Code, d.Message)
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.cause
+}
+
+// Render formats the diagnostic along with the offending line
+// of source, underlining the span with carets
+func (d *Diagnostic) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+
+	if src := d.Span.Source; src != nil {
+		lineNo := fmt.Sprint(d.Span.Line)
+		gutter := strings.Repeat(" ", len(lineNo))
+		text := src.Line(d.Span.Line)
+
+		fmt.Fprintf(&b, "%s--> %s:%d:%d\n", gutter, src.Name, d.Span.Line, d.Span.Column)
+		fmt.Fprintf(&b, "%s |\n", gutter)
+		fmt.Fprintf(&b, "%s | %s\n", lineNo, strings.ReplaceAll(text, "\t", " "))
+
+		// Only underline up to the end of the first line of the span
+		width := 1
+		if d.Span.Length > 0 && d.Span.Offset+d.Span.Length <= len(src.Text) {
+			spanned := src.Text[d.Span.Offset : d.Span.Offset+d.Span.Length]
+			spanned, _, _ = strings.Cut(spanned, "\n")
+			if n := utf8.RuneCountInString(spanned); n > 1 {
+				width = n
+			}
+		}
+		padding := ""
+		if d.Span.Column > 1 {
+			padding = strings.Repeat(" ", d.Span.Column-1)
+		}
+		fmt.Fprintf(&b, "%s | %s%s\n", gutter, padding, strings.Repeat("^", width))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(&b, "  = note: %s\n", note)
+	}
+	if len(d.Trace) > 1 {
+		renderTrace(&b, d.Trace)
+	}
+	return b.String()
+}
+
+// Number of frames shown at each end of a long traceback
+const traceEdge = 10
+
+func renderTrace(b *strings.Builder, trace []StackFrame) {
+	b.WriteString("traceback (most recent call last):\n")
+	for idx, frame := range trace {
+		// Elide the middle of deep recursion
+		if len(trace) > 2*traceEdge && idx >= traceEdge && idx < len(trace)-traceEdge {
+			if idx == traceEdge {
+				fmt.Fprintf(b, "  ... %d more frames ...\n", len(trace)-2*traceEdge)
+			}
+			continue
+		}
+		fmt.Fprintf(b, "  in %s at %s:%d\n", frame.Function, frame.Source, frame.Line)
+	}
+}
+
+// Diagnostics holds every Diagnostic produced by a run
+type Diagnostics []*Diagnostic
+
+func (ds Diagnostics) Error() string {
+	msgs := make([]string, len(ds))
+	for i, d := range ds {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap allows errors.As to find e.g. a RuntimeError
+// behind any of the diagnostics
+func (ds Diagnostics) Unwrap() []error {
+	errs := make([]error, len(ds))
+	for i, d := range ds {
+		errs[i] = d
+	}
+	return errs
+}
+
+// errorOrNil avoids returning a non-nil error interface
+// holding empty Diagnostics
+func (ds Diagnostics) errorOrNil() error {
+	if len(ds) == 0 {
+		return nil
+	}
+	return ds
+}
+
+// ErrorOnToken creates an error diagnostic spanning the token
+func ErrorOnToken(code string, token Token, msg string) *Diagnostic {
+	return &Diagnostic{Severity: SeverityError, Code: code, Span: tokenSpan(token), Message: msg}
+}
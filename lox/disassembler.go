@@ -0,0 +1,98 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+)
+
+// disassembleFunction prints the chunk of function, followed by
+// those of the functions it contains
+func disassembleFunction(w io.Writer, function *funcProto, name string) {
+	disassembleChunk(w, function.chunk, name)
+	for _, constant := range function.chunk.constants {
+		if fn, ok := constant.(*funcProto); ok {
+			fmt.Fprintln(w)
+			disassembleFunction(w, fn, fn.String())
+		}
+	}
+}
+
+func disassembleChunk(w io.Writer, chunk *Chunk, name string) {
+	fmt.Fprintf(w, "== %s ==\n", name)
+	for offset := 0; offset < len(chunk.code); {
+		offset = disassembleInstruction(w, chunk, offset)
+	}
+}
+
+// disassembleInstruction prints the instruction at offset,
+// returning the offset of the next one
+func disassembleInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	fmt.Fprintf(w, "%04d ", offset)
+	if offset > 0 && chunk.positions[offset].line == chunk.positions[offset-1].line {
+		fmt.Fprint(w, "   | ")
+	} else {
+		fmt.Fprintf(w, "%4d ", chunk.positions[offset].line)
+	}
+
+	op := OpCode(chunk.code[offset])
+	switch op {
+	case OP_CONSTANT, OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL,
+		OP_GET_PROPERTY, OP_SET_PROPERTY, OP_GET_SUPER, OP_CLASS, OP_METHOD:
+		return constantInstruction(w, chunk, op, offset)
+	case OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_CALL:
+		fmt.Fprintf(w, "%-16s %4d\n", op, chunk.code[offset+1])
+		return offset + 2
+	case OP_LIST, OP_MAP:
+		fmt.Fprintf(w, "%-16s %4d\n", op, chunk.readShort(offset+1))
+		return offset + 3
+	case OP_JUMP, OP_JUMP_IF_FALSE, OP_FOR_ITER:
+		jump := chunk.readShort(offset + 1)
+		fmt.Fprintf(w, "%-16s %4d -> %d\n", op, offset, offset+3+jump)
+		return offset + 3
+	case OP_LOOP:
+		jump := chunk.readShort(offset + 1)
+		fmt.Fprintf(w, "%-16s %4d -> %d\n", op, offset, offset+3-jump)
+		return offset + 3
+	case OP_TRY:
+		kind := "catch"
+		if chunk.code[offset+1] == handlerFinally {
+			kind = "finally"
+		}
+		jump := chunk.readShort(offset + 2)
+		fmt.Fprintf(w, "%-16s %4d -> %d (%s)\n", op, offset, offset+4+jump, kind)
+		return offset + 4
+	case OP_UNWIND:
+		fmt.Fprintf(w, "%-16s %4d", op, chunk.code[offset+1])
+		if chunk.code[offset+2] == 1 {
+			fmt.Fprint(w, " keep")
+		}
+		fmt.Fprintln(w)
+		return offset + 3
+	case OP_CLOSURE:
+		return closureInstruction(w, chunk, offset)
+	}
+	fmt.Fprintln(w, op)
+	return offset + 1
+}
+
+func constantInstruction(w io.Writer, chunk *Chunk, op OpCode, offset int) int {
+	constant := chunk.readShort(offset + 1)
+	fmt.Fprintf(w, "%-16s %4d '%s'\n", op, constant, stringify(chunk.constants[constant]))
+	return offset + 3
+}
+
+func closureInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	constant := chunk.readShort(offset + 1)
+	function := chunk.constants[constant].(*funcProto)
+	fmt.Fprintf(w, "%-16s %4d %s\n", OP_CLOSURE, constant, function)
+	offset += 3
+	for idx := 0; idx < function.upvalueCount; idx++ {
+		kind := "upvalue"
+		if chunk.code[offset] == 1 {
+			kind = "local"
+		}
+		fmt.Fprintf(w, "%04d    |                     %s %d\n", offset, kind, chunk.code[offset+1])
+		offset += 2
+	}
+	return offset
+}
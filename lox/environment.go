@@ -2,40 +2,65 @@ package lox
 
 type Environment struct {
 	enclosing *Environment
+	// Variables of a local scope, in the order they were
+	// declared. The Resolver gives each one its slot in here.
+	slots []any
+	// Only the global scope looks variables up by name
 	values map[string]any
 }
 
+// NewEnvironment creates a global scope
 func NewEnvironment() *Environment {
 	return &Environment{values: make(map[string]any)}
 }
 
+// NewEnclosedEnv creates a local scope inside encl
 func NewEnclosedEnv(encl *Environment) *Environment {
-	return &Environment{enclosing: encl, values : make(map[string]any)}
+	return &Environment{enclosing: encl}
 }
 
+// Define declares a variable in the scope. Local variables must be
+// defined in the order that the Resolver saw them declared.
 func (e *Environment) Define(name string, value any) {
-	e.values[name] = value
-}
-
-func (e *Environment) Get(name Token) any {
-	if value, ok := e.values[name.Lexeme]; !ok {
-		if e.enclosing != nil {
-			return e.enclosing.Get(name)
-		} else {
-			panic(RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."})
-		}
-	} else {
-		return value
+	if e.values != nil {
+		e.values[name] = value
+		return
 	}
+	e.slots = append(e.slots, value)
 }
 
-func (e *Environment) Assign(name Token, value any) {
+// Get looks up a global variable
+func (e *Environment) Get(name Token) (any, error) {
+	value, ok := e.values[name.Lexeme]
+	if !ok {
+		return nil, RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."}
+	}
+	return value, nil
+}
+
+// Assign sets a global variable, which must already be defined
+func (e *Environment) Assign(name Token, value any) error {
 	if _, ok := e.values[name.Lexeme]; !ok {
-		if e.enclosing != nil {
-			e.enclosing.Assign(name, value)
-		} else {
-			panic(RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."})
-		}
+		return RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."}
+	}
+	e.values[name.Lexeme] = value
+	return nil
+}
+
+// GetAt looks up a local variable a fixed number of scopes away,
+// as determined by the Resolver
+func (e *Environment) GetAt(distance int, slot int) any {
+	return e.ancestor(distance).slots[slot]
+}
+
+func (e *Environment) AssignAt(distance int, slot int, value any) {
+	e.ancestor(distance).slots[slot] = value
+}
+
+func (e *Environment) ancestor(distance int) *Environment {
+	env := e
+	for i := 0; i < distance; i++ {
+		env = env.enclosing
 	}
-	e.Define(name.Lexeme, value)
+	return env
 }
@@ -0,0 +1,42 @@
+package lox
+
+// object is an instance of a class on either backend, which is all
+// that the handling of Error objects they share needs to know
+type object interface {
+	fieldMap() map[string]any
+	// instanceOf reports whether the object's class is class,
+	// or a subclass of it
+	instanceOf(class any) bool
+}
+
+// errorFields returns the fields of the Error instance
+// that err is caught as
+func errorFields(err RuntimeError) map[string]any {
+	return map[string]any{"message": err.msg, "line": float64(err.token.Line)}
+}
+
+// asError returns the fields of value if it is an instance of
+// errorClass, the backend's Error class
+func asError(value any, errorClass any) (map[string]any, bool) {
+	if obj, ok := value.(object); ok && obj.instanceOf(errorClass) {
+		return obj.fieldMap(), true
+	}
+	return nil, false
+}
+
+// markThrown records where an Error object was first thrown
+func markThrown(value any, errorClass any, keyword Token) {
+	if fields, ok := asError(value, errorClass); ok && fields["line"] == nil {
+		fields["line"] = float64(keyword.Line)
+	}
+}
+
+// uncaught converts an exception which reached the top level to
+// a RuntimeError, using the message of Error objects
+func uncaught(t Throw, errorClass any) RuntimeError {
+	msg := stringify(t.value)
+	if fields, ok := asError(t.value, errorClass); ok {
+		msg = stringify(fields["message"])
+	}
+	return RuntimeError{token: t.token, msg: "Uncaught exception: " + msg}
+}
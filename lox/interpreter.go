@@ -2,66 +2,192 @@ package lox
 
 import (
 	"fmt"
+	"io"
+	"strconv"
 )
 
 type Interpreter struct {
+	stdout io.Writer
 	tmp any
-	globals Environment
+	globals *Environment
 	env *Environment
-}
-
-func NewInterpreter() *Interpreter {
-	var globals Environment
-	// globals.values["clock"] =
-
-	// Could have env enclose the global environment?
-	return &Interpreter{globals: globals, env: &globals}
+	// Class of the objects that runtime errors are
+	// caught as, defined by the prelude
+	errorClass *LoxClass
+	// Calls in progress, outermost first
+	frames []callFrame
+	// How the last statement executed completed, set by the
+	// statement visitors in the same way as tmp is set by the
+	// expression visitors
+	completion completion
+	// The value of a 'return' being unwound
+	completionValue any
+	// The Throw or RuntimeError being unwound
+	exception error
+	// Resources used by the current run
+	budget budget
+}
+
+func NewInterpreter(stdout io.Writer) *Interpreter {
+	globals := NewEnvironment()
+	i := &Interpreter{stdout: stdout, globals: globals, env: globals}
+	i.loadPrelude()
+	return i
 }
 
 type Callable interface {
-	call(*Interpreter, []any) any
+	// The paren token of the call expression is passed
+	// through so that errors can be reported at the call site
+	call(i *Interpreter, paren Token, args []any) any
+	// Variadic callables return an arity of Variadic
 	arity() int
 }
 
-// TODO: Improve errors and error handling
-type RuntimeError struct {
-	token Token
-	msg   string
+// DefineNative makes a Go function available to Lox code as a
+// global with the given name. Pass Variadic as the arity to
+// accept any number of arguments.
+func (i *Interpreter) DefineNative(name string, arity int, fn NativeFn) {
+	i.globals.Define(name, NewNativeFunction(name, arity, fn))
 }
 
-func (err RuntimeError) Error() string {
-	return err.msg
-}
+// completion is how a statement finished executing. Anything but
+// completeNormal makes the enclosing statements stop executing
+// until they reach the one which handles it, e.g. the loop for a
+// 'break' or the function call for a 'return'.
+type completion int
+
+const (
+	completeNormal completion = iota
+	completeReturn
+	completeBreak
+	completeContinue
+	completeThrow
+)
 
-// Not actually an error - used for breaking out of e.g. functions 
-// with a 'return' statement
-type Return struct {
+// Throw is an exception thrown by a 'throw' statement. It unwinds as
+// a completeThrow, which the expression visitors pass on as well,
+// and is returned as an error to natives that call back into Lox.
+type Throw struct {
+	token Token
 	value any
 }
 
-func (ret Return) Error() string {
-	return stringify(ret.value)
+func (t Throw) Error() string {
+	return stringify(t.value)
 }
 
-func (i *Interpreter) Interpret(statements []Stmt) {
+// Interpret executes the statements, returning the value of the last
+// one if it is an expression statement
+func (i *Interpreter) Interpret(statements []Stmt) (value Value, err error) {
 	defer func() {
+		// Only a halt is panicked with, to stop the script
+		// from wherever it was exceeding a limit
 		if r := recover(); r != nil {
-			// Determine that we are recovering from a
-			// runtimeError
-			if re, ok := r.(RuntimeError); ok {
-				ReportRuntimeError(re)
-			} else {
+			h, ok := r.(halt)
+			if !ok {
 				panic(r)
 			}
+			h.trace = stackTrace(i.frames, h.token)
+			i.reset()
+			value, err = nil, h
 		}
-
 	}()
 	for _, stmt := range statements {
+		i.tmp = nil
 		i.execute(stmt)
+		// Only exceptions can reach the top level
+		if i.completion == completeThrow {
+			re, ok := i.exception.(RuntimeError)
+			if !ok {
+				re = uncaught(i.exception.(Throw), i.errorClass)
+			}
+			return nil, i.fail(re)
+		}
+		if _, ok := stmt.(*ExpressionStmt); ok {
+			value = i.tmp
+		} else {
+			value = nil
+		}
 	}
+	return value, nil
+}
+
+// location is where a local variable is stored: the number of
+// scopes between a reference to it and the scope it was declared
+// in, and its slot in that scope
+type location struct {
+	depth int
+	slot int
+}
+
+// fail adds the traceback to an error which ended the program, and
+// resets the interpreter for the next call
+func (i *Interpreter) fail(re RuntimeError) RuntimeError {
+	re.trace = stackTrace(i.frames, re.token)
+	i.reset()
+	return re
+}
+
+func (i *Interpreter) reset() {
+	// Leave the interpreter in the global scope
+	// for the next call
+	i.env = i.globals
+	i.frames = i.frames[:0]
+	i.completion = completeNormal
+	i.exception = nil
+}
+
+// raise starts unwinding err, a RuntimeError or Throw, as an exception
+func (i *Interpreter) raise(err error) {
+	i.completion = completeThrow
+	i.exception = err
+}
+
+// throwing reports whether an exception is being unwound, which
+// the visitors check after evaluating each operand
+func (i *Interpreter) throwing() bool {
+	return i.completion == completeThrow
+}
+
+// allocate implements caller
+func (i *Interpreter) allocate(token Token, size int) {
+	if err := i.budget.allocate(size); err != nil {
+		panic(halt{err: err, token: token})
+	}
+}
+
+// resolve is called by the Resolver to record the location of the
+// variable that expr refers to. It's kept on the expression, so that
+// it's freed along with the rest of the program.
+func (i *Interpreter) resolve(expr Expr, depth int, slot int) {
+	loc := &location{depth: depth, slot: slot}
+	switch e := expr.(type) {
+	case *Variable:
+		e.local = loc
+	case *Assign:
+		e.local = loc
+	case *This:
+		e.local = loc
+	case *Super:
+		e.local = loc
+	}
+}
+
+func (i *Interpreter) lookUpVariable(name Token, loc *location) any {
+	if loc != nil {
+		return i.env.GetAt(loc.depth, loc.slot)
+	}
+	value, err := i.globals.Get(name)
+	if err != nil {
+		i.raise(err)
+	}
+	return value
 }
 
 func (i *Interpreter) execute(stmt Stmt) {
+	if err := i.budget.step(); err != nil {
+		panic(halt{err: err, token: stmtToken(stmt)})
+	}
 	stmt.Accept(i)
 }
 
@@ -75,6 +201,9 @@ func (i *Interpreter) visitVarStmt(stmt *VarStmt) {
 	var value any
 	if init := stmt.Initialiser; init != nil {
 		value = i.evaluate(init)
+		if i.throwing() {
+			return
+		}
 	}
 	i.env.Define(stmt.Name.Lexeme, value)
 }
@@ -82,7 +211,15 @@ func (i *Interpreter) visitVarStmt(stmt *VarStmt) {
 // visitAssignExpr implements StmtVisitor.
 func (i *Interpreter) visitAssignExpr(expr *Assign) {
 	value := i.evaluate(expr.Value)
-	i.env.Assign(expr.Name, value)
+	if i.throwing() {
+		return
+	}
+	if loc := expr.local; loc != nil {
+		i.env.AssignAt(loc.depth, loc.slot, value)
+	} else if err := i.globals.Assign(expr.Name, value); err != nil {
+		i.raise(err)
+		return
+	}
 	i.tmp = value
 }
 
@@ -92,7 +229,10 @@ func (i *Interpreter) visitExpressionStmt(stmt *ExpressionStmt) {
 
 func (i *Interpreter) visitPrintStmt(stmt *PrintStmt) {
 	value := i.evaluate(stmt.Expr)
-	fmt.Println(stringify(value))
+	if i.throwing() {
+		return
+	}
+	fmt.Fprintln(i.stdout, stringify(value))
 }
 
 func (i *Interpreter) visitBlockStmt(stmt *BlockStmt) {
@@ -101,7 +241,11 @@ func (i *Interpreter) visitBlockStmt(stmt *BlockStmt) {
 }
 
 func (i *Interpreter) visitIfStmt(stmt *IfStmt) {
-	if i.isTruthy(i.evaluate(stmt.expr)) {
+	condition := i.evaluate(stmt.expr)
+	if i.throwing() {
+		return
+	}
+	if isTruthy(condition) {
 		i.execute(stmt.thenBranch)
 	} else if stmt.elseBranch != nil {
 		i.execute(stmt.elseBranch)
@@ -109,48 +253,228 @@ func (i *Interpreter) visitIfStmt(stmt *IfStmt) {
 }
 
 func (i *Interpreter) visitWhileStmt(stmt *WhileStmt) {
-	for i.isTruthy(i.evaluate(stmt.expr)) {
+	for {
+		condition := i.evaluate(stmt.expr)
+		if i.throwing() || !isTruthy(condition) {
+			return
+		}
 		i.execute(stmt.body)
+		if i.endIteration() {
+			return
+		}
+		if stmt.increment != nil {
+			i.evaluate(stmt.increment)
+			if i.throwing() {
+				return
+			}
+		}
 	}
 }
 
+func (i *Interpreter) visitForInStmt(stmt *ForInStmt) {
+	iterable := i.evaluate(stmt.iterable)
+	if i.throwing() {
+		return
+	}
+	next, err := newIterator(i, stmt.keyword, iterable)
+	if err != nil {
+		i.raise(err)
+		return
+	}
+	for {
+		value, ok, err := next()
+		if err != nil {
+			i.raise(err)
+			return
+		}
+		if !ok {
+			break
+		}
+		env := NewEnclosedEnv(i.env)
+		env.Define(stmt.name.Lexeme, value)
+		i.executeBlock([]Stmt{stmt.body}, env)
+		if i.endIteration() {
+			break
+		}
+	}
+}
+
+// endIteration handles the completion of a loop body, and reports
+// whether the loop should stop
+func (i *Interpreter) endIteration() bool {
+	switch i.completion {
+	case completeNormal:
+		return false
+	case completeBreak:
+		i.completion = completeNormal
+		return true
+	case completeContinue:
+		i.completion = completeNormal
+		return false
+	}
+	// Returns and exceptions carry on to the enclosing statements
+	return true
+}
+
+func (i *Interpreter) visitThrowStmt(stmt *ThrowStmt) {
+	value := i.evaluate(stmt.value)
+	if i.throwing() {
+		return
+	}
+	markThrown(value, i.errorClass, stmt.keyword)
+	i.raise(Throw{token: stmt.keyword, value: value})
+}
+
+func (i *Interpreter) visitTryStmt(stmt *TryStmt) {
+	depth := len(i.frames)
+	i.executeBlock(stmt.body, NewEnclosedEnv(i.env))
+	if i.throwing() && stmt.catchBody != nil {
+		// Runtime errors are caught as Error objects
+		var caught any
+		switch e := i.exception.(type) {
+		case Throw:
+			caught = e.value
+		case RuntimeError:
+			caught = i.errorObject(e)
+		}
+		// Discard the frames of the calls that were unwound
+		i.frames = i.frames[:depth]
+		i.completion, i.exception = completeNormal, nil
+		env := NewEnclosedEnv(i.env)
+		env.Define(stmt.catchName.Lexeme, caught)
+		i.executeBlock(stmt.catchBody, env)
+	}
+	if stmt.finallyBody != nil {
+		i.finally(stmt.finallyBody, depth)
+	}
+}
+
+// finally runs the finally block of a try statement, however the rest
+// of the statement completed. If the block itself completes abruptly,
+// e.g. with a 'return', that replaces the unwinding that was in
+// progress.
+func (i *Interpreter) finally(body []Stmt, depth int) {
+	completion, value, exception := i.completion, i.completionValue, i.exception
+	// The frames of the calls an exception unwound are put back
+	// afterwards, for its traceback
+	unwound := append([]callFrame(nil), i.frames[depth:]...)
+	i.frames = i.frames[:depth]
+	i.completion, i.exception = completeNormal, nil
+	i.executeBlock(body, NewEnclosedEnv(i.env))
+	if i.completion != completeNormal {
+		return
+	}
+	i.completion, i.completionValue, i.exception = completion, value, exception
+	i.frames = append(i.frames, unwound...)
+}
+
+// errorObject converts a RuntimeError to an Error instance
+func (i *Interpreter) errorObject(err RuntimeError) *LoxInstance {
+	return &LoxInstance{class: i.errorClass, fields: errorFields(err)}
+}
+
+func (i *Interpreter) visitBreakStmt(stmt *BreakStmt) {
+	i.completion = completeBreak
+}
+
+func (i *Interpreter) visitContinueStmt(stmt *ContinueStmt) {
+	i.completion = completeContinue
+}
+
 func (i *Interpreter) visitReturnStmt(stmt *ReturnStmt) {
 	var value any
 	if stmt.value != nil {
 		value = i.evaluate(stmt.value)
+		if i.throwing() {
+			return
+		}
 	}
-	panic(Return{value: value})
+	i.completion = completeReturn
+	i.completionValue = value
 }
 
+// executeBlock executes the statements in env, stopping early if one
+// completes abruptly
 func (i *Interpreter) executeBlock(statements []Stmt, env *Environment) {
 	prev := i.env
-	defer func() {
-		// Reset state of interpreter
-		i.env = prev
-	}()
 	i.env = env
 	for _, statement := range statements {
 		i.execute(statement)
+		if i.completion != completeNormal {
+			break
+		}
 	}
+	i.env = prev
 }
 
 func (i *Interpreter) visitCallExpr(expr *Call) {
 	callee := i.evaluate(expr.callee)
+	if i.throwing() {
+		return
+	}
 
 	var args []any
 	for _, a := range expr.arguments {
 		args = append(args, i.evaluate(a))
+		if i.throwing() {
+			return
+		}
 	}
 
-	if function, ok := callee.(Callable); !ok {
-		panic(RuntimeError{token: expr.paren, msg: "Not a callable expression"})
-	} else {
-		if len(args) != function.arity() {
-			msg := fmt.Sprintf("Expected %d argument but received %d", function.arity(), len(args))
-			panic(RuntimeError{token: expr.paren, msg: msg})
-		}
-		i.tmp = function.call(i, args)
+	i.tmp = i.call(callee, expr.paren, args)
+}
+
+// call checks that callee can be called with args, and calls it.
+// Errors are reported at the given token.
+func (i *Interpreter) call(callee any, paren Token, args []any) any {
+	function, ok := callee.(Callable)
+	if !ok {
+		i.raise(RuntimeError{token: paren, msg: "Not a callable expression"})
+		return nil
+	}
+	if function.arity() != Variadic && len(args) != function.arity() {
+		msg := fmt.Sprintf("Expected %d argument but received %d", function.arity(), len(args))
+		i.raise(RuntimeError{token: paren, msg: msg})
+		return nil
+	}
+	if err := i.budget.call(len(i.frames) + 1); err != nil {
+		panic(halt{err: err, token: paren})
+	}
+	// Frames are only popped on a normal return, so that they are
+	// still there for the traceback of an uncaught error
+	i.frames = append(i.frames, callFrame{name: callableName(function), site: paren})
+	value := function.call(i, paren, args)
+	if i.throwing() {
+		return nil
+	}
+	i.frames = i.frames[:len(i.frames)-1]
+	return value
+}
+
+// callValue implements caller
+func (i *Interpreter) callValue(callee any, token Token, args []any) (any, error) {
+	value := i.call(callee, token, args)
+	if i.throwing() {
+		// The native which called back will raise it again
+		err := i.exception
+		i.completion, i.exception = completeNormal, nil
+		return nil, err
+	}
+	return value, nil
+}
+
+// invoke implements caller
+func (i *Interpreter) invoke(object any, token Token, name string, args []any) (any, bool, error) {
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		return nil, false, nil
 	}
+	method := instance.class.findMethod(name)
+	if method == nil {
+		return nil, false, nil
+	}
+	value, err := i.callValue(method.bind(instance), token, args)
+	return value, true, err
 }
 
 func (i *Interpreter) visitFunctionStmt(stmt *FunctionStmt) {
@@ -158,33 +482,259 @@ func (i *Interpreter) visitFunctionStmt(stmt *FunctionStmt) {
 	i.env.Define(stmt.name.Lexeme, function)
 }
 
+func (i *Interpreter) visitLambdaExpr(expr *Lambda) {
+	i.tmp = &LoxFunction{decl: expr.decl, closure: i.env}
+}
+
+func (i *Interpreter) visitClassStmt(stmt *ClassStmt) {
+	var superclass *LoxClass
+	if stmt.superclass != nil {
+		value := i.evaluate(stmt.superclass)
+		if i.throwing() {
+			return
+		}
+		class, ok := value.(*LoxClass)
+		if !ok {
+			i.raise(RuntimeError{token: stmt.superclass.Name, msg: "Superclass must be a class"})
+			return
+		}
+		superclass = class
+	}
+
+	if superclass != nil {
+		i.env = NewEnclosedEnv(i.env)
+		i.env.Define("super", superclass)
+	}
+
+	methods := make(map[string]*LoxFunction)
+	for _, method := range stmt.methods {
+		isInit := method.name.Lexeme == "init"
+		methods[method.name.Lexeme] = &LoxFunction{decl: method, closure: i.env, isInitialiser: isInit}
+	}
+
+	class := &LoxClass{name: stmt.name.Lexeme, superclass: superclass, methods: methods}
+
+	if superclass != nil {
+		i.env = i.env.enclosing
+	}
+	// Methods only look the class up once they are called, so it
+	// can be defined last
+	i.env.Define(stmt.name.Lexeme, class)
+}
+
+func (i *Interpreter) visitGetExpr(expr *Get) {
+	object := i.evaluate(expr.object)
+	if i.throwing() {
+		return
+	}
+	var value any
+	var err error
+	switch object := object.(type) {
+	case *LoxInstance:
+		value, err = object.Get(expr.name)
+	case *LoxList:
+		value, err = object.Get(i, expr.name)
+	case *LoxMap:
+		value, err = object.Get(expr.name)
+	case *LoxModule:
+		value, err = object.Get(expr.name)
+	default:
+		err = RuntimeError{token: expr.name, msg: "Only instances have properties"}
+	}
+	if err != nil {
+		i.raise(err)
+		return
+	}
+	i.tmp = value
+}
+
+func (i *Interpreter) visitSetExpr(expr *Set) {
+	// Both operands are evaluated before the object is checked,
+	// as in the bytecode backend
+	object := i.evaluate(expr.object)
+	if i.throwing() {
+		return
+	}
+	value := i.evaluate(expr.value)
+	if i.throwing() {
+		return
+	}
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		i.raise(RuntimeError{token: expr.name, msg: "Only instances have fields"})
+		return
+	}
+	instance.Set(expr.name, value)
+	i.tmp = value
+}
+
+func (i *Interpreter) visitThisExpr(expr *This) {
+	i.tmp = i.lookUpVariable(expr.keyword, expr.local)
+}
+
+func (i *Interpreter) visitSuperExpr(expr *Super) {
+	distance := expr.local.depth
+	superclass := i.env.GetAt(distance, 0).(*LoxClass)
+	// 'this' is always bound in the scope just inside 'super'
+	object := i.env.GetAt(distance-1, 0).(*LoxInstance)
+
+	method := superclass.findMethod(expr.method.Lexeme)
+	if method == nil {
+		i.raise(RuntimeError{token: expr.method, msg: "Undefined property '" + expr.method.Lexeme + "'."})
+		return
+	}
+	i.tmp = method.bind(object)
+}
+
+func (i *Interpreter) visitListLiteralExpr(expr *ListLiteral) {
+	i.allocate(expr.bracket, len(expr.elements))
+	elements := make([]any, len(expr.elements))
+	for idx, element := range expr.elements {
+		if elements[idx] = i.evaluate(element); i.throwing() {
+			return
+		}
+	}
+	i.tmp = NewLoxList(elements)
+}
+
+func (i *Interpreter) visitMapLiteralExpr(expr *MapLiteral) {
+	i.allocate(expr.brace, len(expr.keys))
+	m := NewLoxMap()
+	for idx := range expr.keys {
+		key := i.evaluate(expr.keys[idx])
+		if i.throwing() {
+			return
+		}
+		value := i.evaluate(expr.values[idx])
+		if i.throwing() {
+			return
+		}
+		m.Set(key, value)
+	}
+	i.tmp = m
+}
+
+// indexable is implemented by the collection types that
+// support subscript expressions
+type indexable interface {
+	Index(token Token, index any) (any, error)
+	SetIndex(token Token, index any, value any) error
+}
+
+func (i *Interpreter) visitIndexExpr(expr *Index) {
+	object := i.evaluate(expr.object)
+	if i.throwing() {
+		return
+	}
+	index := i.evaluate(expr.index)
+	if i.throwing() {
+		return
+	}
+	collection, ok := object.(indexable)
+	if !ok {
+		i.raise(RuntimeError{token: expr.bracket, msg: "Only lists and maps can be indexed"})
+		return
+	}
+	value, err := collection.Index(expr.bracket, index)
+	if err != nil {
+		i.raise(err)
+		return
+	}
+	i.tmp = value
+}
+
+func (i *Interpreter) visitIndexSetExpr(expr *IndexSet) {
+	object := i.evaluate(expr.object)
+	if i.throwing() {
+		return
+	}
+	index := i.evaluate(expr.index)
+	if i.throwing() {
+		return
+	}
+	value := i.evaluate(expr.value)
+	if i.throwing() {
+		return
+	}
+	collection, ok := object.(indexable)
+	if !ok {
+		i.raise(RuntimeError{token: expr.bracket, msg: "Only lists and maps can be indexed"})
+		return
+	}
+	if m, ok := collection.(*LoxMap); ok && !m.Has(index) {
+		i.allocate(expr.bracket, 1)
+	}
+	if err := collection.SetIndex(expr.bracket, index, value); err != nil {
+		i.raise(err)
+		return
+	}
+	i.tmp = value
+}
+
+func (i *Interpreter) visitSliceExpr(expr *Slice) {
+	object := i.evaluate(expr.object)
+	if i.throwing() {
+		return
+	}
+	var start, end any
+	if expr.start != nil {
+		if start = i.evaluate(expr.start); i.throwing() {
+			return
+		}
+	}
+	if expr.end != nil {
+		if end = i.evaluate(expr.end); i.throwing() {
+			return
+		}
+	}
+	list, ok := object.(*LoxList)
+	if !ok {
+		i.raise(RuntimeError{token: expr.bracket, msg: "Only lists can be sliced"})
+		return
+	}
+	slice, err := list.Slice(expr.bracket, start, end)
+	if err != nil {
+		i.raise(err)
+		return
+	}
+	i.allocate(expr.bracket, len(slice.elements))
+	i.tmp = slice
+}
+
 func (i *Interpreter) visitBinaryExpr(expr *Binary) {
 	left := i.evaluate(expr.Left)
+	if i.throwing() {
+		return
+	}
 	right := i.evaluate(expr.Right)
+	if i.throwing() {
+		return
+	}
+
+	switch expr.Op.Type {
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL, MINUS, SLASH, STAR:
+		if err := checkNumberOperands(expr.Op, left, right); err != nil {
+			i.raise(err)
+			return
+		}
+	}
 
 	switch expr.Op.Type {
 	case GREATER:
 		// Number literals are parsed as float64s
 		// by the Scanner
-		checkNumberOperands(expr.Op, left, right)
 		i.tmp = left.(float64) > right.(float64)
 	case GREATER_EQUAL:
-		checkNumberOperands(expr.Op, left, right)
 		i.tmp = left.(float64) >= right.(float64)
 	case LESS:
-		checkNumberOperands(expr.Op, left, right)
 		i.tmp = left.(float64) < right.(float64)
 	case LESS_EQUAL:
-		checkNumberOperands(expr.Op, left, right)
 		i.tmp = left.(float64) <= right.(float64)
 	case BANG_EQUAL:
-		checkNumberOperands(expr.Op, left, right)
-		i.tmp = !i.isEqual(left, right)
+		i.tmp = !isEqual(left, right)
 	case EQUAL_EQUAL:
-		checkNumberOperands(expr.Op, left, right)
-		i.tmp = i.isEqual(left, right)
+		i.tmp = isEqual(left, right)
 	case MINUS:
-		checkNumberOperands(expr.Op, left, right)
 		i.tmp = left.(float64) - right.(float64)
 	case PLUS:
 		l, okLeft := left.(float64)
@@ -196,16 +746,14 @@ func (i *Interpreter) visitBinaryExpr(expr *Binary) {
 		lStr, okLeft := left.(string)
 		rStr, okRight := right.(string)
 		if okLeft && okRight {
+			i.allocate(expr.Op, len(lStr)+len(rStr))
 			i.tmp = lStr + rStr
 			break
 		}
-		err := RuntimeError{token: expr.Op, msg: "Operands must be two numbers or two strings"}
-		panic(err)
+		i.raise(RuntimeError{token: expr.Op, msg: "Operands must be two numbers or two strings"})
 	case SLASH:
-		checkNumberOperands(expr.Op, left, right)
 		i.tmp = left.(float64) / right.(float64)
 	case STAR:
-		checkNumberOperands(expr.Op, left, right)
 		i.tmp = left.(float64) * right.(float64)
 	default:
 		i.tmp = nil
@@ -214,13 +762,16 @@ func (i *Interpreter) visitBinaryExpr(expr *Binary) {
 
 func (i *Interpreter) visitLogicalExpr(expr *Logical) {
 	left := i.evaluate(expr.left)
+	if i.throwing() {
+		return
+	}
 	if expr.op.Type == OR {
-		if i.isTruthy(left) {
+		if isTruthy(left) {
 			i.tmp = left 
 			return
 		}
 	} else {
-		if !i.isTruthy(left) {
+		if !isTruthy(left) {
 			i.tmp = left 
 			return
 		}
@@ -238,24 +789,28 @@ func (i *Interpreter) visitGroupingExpr(expr *Grouping) {
 
 func (i *Interpreter) visitUnaryExpr(expr *Unary) {
 	i.tmp = i.evaluate(expr.Right)
+	if i.throwing() {
+		return
+	}
 
 	switch expr.Op.Type {
 	case MINUS:
-		checkNumberOperand(expr.Op, i.tmp)
+		if err := checkNumberOperand(expr.Op, i.tmp); err != nil {
+			i.raise(err)
+			return
+		}
 		i.tmp = -i.tmp.(float64)
 	case BANG:
-		i.tmp = !i.isTruthy(i.tmp)
+		i.tmp = !isTruthy(i.tmp)
 	}
-
-	i.tmp = nil
 }
 
 // visitVariableExpr implements ExprVisitor.
 func (i *Interpreter) visitVariableExpr(v *Variable) {
-	i.tmp = i.env.Get(v.Name)
+	i.tmp = i.lookUpVariable(v.Name, v.local)
 }
 
-func (i *Interpreter) isTruthy(obj any) bool {
+func isTruthy(obj any) bool {
 	if obj == nil {
 		return false
 	}
@@ -271,7 +826,7 @@ func (i *Interpreter) isTruthy(obj any) bool {
 	return true
 }
 
-func (i *Interpreter) isEqual(left any, right any) bool {
+func isEqual(left any, right any) bool {
 	if left == nil && right == nil {
 		return true
 	}
@@ -285,24 +840,34 @@ func (i *Interpreter) isEqual(left any, right any) bool {
 	return left == right
 }
 
-func checkNumberOperand(token Token, operand any) {
+func checkNumberOperand(token Token, operand any) error {
 	if _, ok := operand.(float64); !ok {
-		err := RuntimeError{token: token, msg: "Operand must be a number"}
-		// Panic as we need to unwind call stack
-		panic(err)
+		return RuntimeError{token: token, msg: "Operand must be a number"}
 	}
+	return nil
 }
 
-func checkNumberOperands(token Token, left any, right any) {
+func checkNumberOperands(token Token, left any, right any) error {
 	_, okLeft := left.(float64)
 	_, okRight := right.(float64)
 	if !(okLeft && okRight) {
-		err := RuntimeError{token: token, msg: "Operands must be numbers"}
-		// Panic as we need to unwind call stack
-		panic(err)
+		return RuntimeError{token: token, msg: "Operands must be numbers"}
 	}
+	return nil
 }
 
 func stringify(obj any) string {
+	if obj == nil {
+		return "nil"
+	}
 	return fmt.Sprint(obj)
 }
+
+// repr is like stringify, but quotes strings so that they can be
+// told apart when shown inside a collection
+func repr(obj any) string {
+	if s, ok := obj.(string); ok {
+		return strconv.Quote(s)
+	}
+	return stringify(obj)
+}
@@ -0,0 +1,180 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ioModule lets scripts read and write files, read standard input
+// and write to standard error
+func ioModule(h *host) *LoxModule {
+	m := newModule("io")
+
+	// readFile reads the whole of the file at path, after checking
+	// that it could fit in the allocation limit
+	readFile := func(fn string, args []any) (string, error) {
+		if err := h.caps.require(fn, CapFSRead); err != nil {
+			return "", err
+		}
+		path, err := stringArg(fn, args, 0)
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Stat(path); err == nil {
+			if err := h.budget.fits(int(info.Size())); err != nil {
+				return "", err
+			}
+		}
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	}
+
+	m.native("readFile", 1, func(args []any) (any, error) {
+		return readFile("io.readFile", args)
+	})
+
+	// Returns a list of the lines in the file, without their
+	// line endings
+	m.native("readLines", 1, func(args []any) (any, error) {
+		contents, err := readFile("io.readLines", args)
+		if err != nil {
+			return nil, err
+		}
+		// The list is counted once it's returned, but not the lines
+		if err := h.budget.allocate(len(contents)); err != nil {
+			return nil, err
+		}
+		contents = strings.TrimSuffix(contents, "\n")
+		if contents == "" {
+			return NewLoxList(nil), nil
+		}
+		lines := strings.Split(contents, "\n")
+		elements := make([]any, len(lines))
+		for idx, line := range lines {
+			elements[idx] = strings.TrimSuffix(line, "\r")
+		}
+		return NewLoxList(elements), nil
+	})
+
+	// writeFile writes contents to the file at path, creating it if
+	// it doesn't exist, and otherwise either replacing or appending
+	// to what's there
+	writeFile := func(fn string, path string, contents string, flag int) error {
+		if err := h.caps.require(fn, CapFSWrite); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0666)
+		if err != nil {
+			return err
+		}
+		_, err = file.WriteString(contents)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+
+	// Replaces the contents of the file
+	m.native("writeFile", 2, func(args []any) (any, error) {
+		path, err := stringArg("io.writeFile", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		contents, err := stringArg("io.writeFile", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return nil, writeFile("io.writeFile", path, contents, os.O_TRUNC)
+	})
+
+	m.native("appendFile", 2, func(args []any) (any, error) {
+		path, err := stringArg("io.appendFile", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		contents, err := stringArg("io.appendFile", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return nil, writeFile("io.appendFile", path, contents, os.O_APPEND)
+	})
+
+	// Replaces the contents of the file with the elements of a list,
+	// which needn't be strings, each on its own line
+	m.native("writeLines", 2, func(args []any) (any, error) {
+		path, err := stringArg("io.writeLines", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		list, err := listArg("io.writeLines", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for _, element := range list.elements {
+			b.WriteString(stringify(element))
+			b.WriteByte('\n')
+		}
+		return nil, writeFile("io.writeLines", path, b.String(), os.O_TRUNC)
+	})
+
+	// Returns the names of the entries in a directory, sorted
+	m.native("listDir", 1, func(args []any) (any, error) {
+		if err := h.caps.require("io.listDir", CapFSRead); err != nil {
+			return nil, err
+		}
+		path, err := stringArg("io.listDir", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]any, len(entries))
+		for idx, entry := range entries {
+			name := entry.Name()
+			if err := h.budget.allocate(len(name)); err != nil {
+				return nil, err
+			}
+			names[idx] = name
+		}
+		return NewLoxList(names), nil
+	})
+
+	// Reads a line from standard input, without its line ending.
+	// Returns nil at the end of the input.
+	m.native("readLine", 0, func(args []any) (any, error) {
+		if err := h.caps.require("io.readLine", CapStdio); err != nil {
+			return nil, err
+		}
+		if h.stdin == nil {
+			return nil, nil
+		}
+		line, err := h.stdin.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil, nil
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		return strings.TrimSuffix(line, "\r"), nil
+	})
+
+	// Prints a value to standard error, as print does to
+	// standard output
+	m.native("eprint", 1, func(args []any) (any, error) {
+		if err := h.caps.require("io.eprint", CapStdio); err != nil {
+			return nil, err
+		}
+		_, err := fmt.Fprintln(h.stderr, stringify(args[0]))
+		return nil, err
+	})
+
+	return m
+}
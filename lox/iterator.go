@@ -0,0 +1,72 @@
+package lox
+
+import "unicode/utf8"
+
+// newIterator returns a function which produces the successive values
+// of a for-in loop over iterable, and false once there are no more.
+//
+// Lists yield their elements, maps their keys and strings their
+// characters. Instances take part through an 'iter()' method,
+// which returns an object with 'hasNext()' and 'next()' methods.
+func newIterator(c caller, token Token, iterable any) (func() (any, bool, error), error) {
+	switch it := iterable.(type) {
+	case *LoxList:
+		// Check the length each time, so that elements pushed
+		// during the loop are visited
+		idx := 0
+		return func() (any, bool, error) {
+			if idx >= len(it.elements) {
+				return nil, false, nil
+			}
+			idx++
+			return it.elements[idx-1], true, nil
+		}, nil
+	case *LoxMap:
+		return sliceIterator(it.Keys()), nil
+	case string:
+		offset := 0
+		return func() (any, bool, error) {
+			if offset >= len(it) {
+				return nil, false, nil
+			}
+			_, size := utf8.DecodeRuneInString(it[offset:])
+			offset += size
+			return it[offset-size : offset], true, nil
+		}, nil
+	}
+
+	iter, ok, err := c.invoke(iterable, token, "iter", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, RuntimeError{token: token, msg: "Can only iterate over lists, maps, strings and iterable objects"}
+	}
+	// Calls a method of the protocol with no arguments
+	call := func(name string) (any, error) {
+		value, ok, err := c.invoke(iter, token, name, nil)
+		if err == nil && !ok {
+			err = RuntimeError{token: token, msg: "Iterator has no '" + name + "()' method"}
+		}
+		return value, err
+	}
+	return func() (any, bool, error) {
+		hasNext, err := call("hasNext")
+		if err != nil || !isTruthy(hasNext) {
+			return nil, false, err
+		}
+		value, err := call("next")
+		return value, err == nil, err
+	}, nil
+}
+
+func sliceIterator(values []any) func() (any, bool, error) {
+	idx := 0
+	return func() (any, bool, error) {
+		if idx >= len(values) {
+			return nil, false, nil
+		}
+		idx++
+		return values[idx-1], true, nil
+	}
+}
@@ -0,0 +1,289 @@
+package lox
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Limits bounds the resources that one run of a script can use, so
+// that untrusted scripts can be run safely. Zero fields are unlimited,
+// apart from MaxCallDepth.
+type Limits struct {
+	// MaxSteps is the most statements that the TreeWalk backend,
+	// or instructions that the Bytecode backend, can execute
+	MaxSteps int
+	// MaxCallDepth is the most calls that can be in progress at
+	// once. DefaultMaxCallDepth is used if it is zero, as deeper
+	// recursion could overflow the Go stack.
+	MaxCallDepth int
+	// MaxAllocation is the most that the script can allocate,
+	// counting the bytes of each string it creates and the
+	// elements of each list and map
+	MaxAllocation int
+}
+
+// DefaultMaxCallDepth is the call depth limit when none is set
+const DefaultMaxCallDepth = 10000
+
+// WithLimits sets the limits on each run of a script by the VM
+func WithLimits(limits Limits) Option {
+	return func(vm *VM) {
+		vm.limits = limits
+	}
+}
+
+// Each limit stops a script with its own error type, which can be
+// found in the Diagnostics with errors.As. Scripts can't catch these
+// errors, and finally blocks aren't run for them.
+
+// StepLimitError is returned when a script exceeds Limits.MaxSteps
+type StepLimitError struct {
+	Limit int
+}
+
+func (e StepLimitError) Error() string {
+	return fmt.Sprintf("Exceeded the limit of %d steps", e.Limit)
+}
+
+// CallDepthError is returned when a script exceeds Limits.MaxCallDepth
+type CallDepthError struct {
+	Limit int
+}
+
+func (e CallDepthError) Error() string {
+	return fmt.Sprintf("Exceeded the maximum call depth of %d", e.Limit)
+}
+
+// AllocationLimitError is returned when a script exceeds
+// Limits.MaxAllocation
+type AllocationLimitError struct {
+	Limit int
+}
+
+func (e AllocationLimitError) Error() string {
+	return fmt.Sprintf("Exceeded the allocation limit of %d", e.Limit)
+}
+
+// CancelledError is returned when the context of a run is cancelled
+// or passes its deadline. It wraps the context's error.
+type CancelledError struct {
+	Err error
+}
+
+func (e CancelledError) Error() string {
+	return "Cancelled: " + e.Err.Error()
+}
+
+func (e CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// halt is panicked with to stop a script which exceeds a limit. The
+// backends let it unwind past every 'try' statement.
+type halt struct {
+	err   error
+	token Token
+	// Set once the script has been stopped
+	trace []StackFrame
+}
+
+func (h halt) Error() string {
+	return h.err.Error()
+}
+
+// Diagnostic describes the error, pointing at the token
+// where the script was stopped
+func (h halt) Diagnostic() *Diagnostic {
+	d := ErrorOnToken(CodeRuntime, h.token, h.err.Error())
+	d.Trace = h.trace
+	d.cause = h.err
+	return d
+}
+
+// How many steps pass between checks for cancellation
+const pollInterval = 1024
+
+// budget tracks a run's use of the resources bounded by its Limits.
+// Each method returns an error once a limit is exceeded.
+type budget struct {
+	limits    Limits
+	ctx       context.Context
+	steps     int
+	allocated int
+	// The step at which to next check the step limit and context
+	nextCheck int
+}
+
+// start resets the budget for a run which can be cancelled with ctx
+func (b *budget) start(ctx context.Context) {
+	b.ctx = ctx
+	b.steps = 0
+	b.allocated = 0
+	b.schedule()
+}
+
+func (b *budget) schedule() {
+	b.nextCheck = math.MaxInt
+	if b.limits.MaxSteps > 0 {
+		b.nextCheck = b.limits.MaxSteps + 1
+	}
+	if b.ctx != nil && b.ctx.Done() != nil && b.steps+pollInterval < b.nextCheck {
+		b.nextCheck = b.steps + pollInterval
+	}
+}
+
+// step counts a statement or instruction
+func (b *budget) step() error {
+	b.steps++
+	if b.steps < b.nextCheck {
+		return nil
+	}
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return StepLimitError{Limit: b.limits.MaxSteps}
+	}
+	if b.ctx != nil && b.ctx.Err() != nil {
+		return CancelledError{Err: b.ctx.Err()}
+	}
+	b.schedule()
+	return nil
+}
+
+// call checks the number of calls in progress, including a new one
+func (b *budget) call(depth int) error {
+	limit := b.limits.MaxCallDepth
+	if limit == 0 {
+		limit = DefaultMaxCallDepth
+	}
+	if depth > limit {
+		return CallDepthError{Limit: limit}
+	}
+	return nil
+}
+
+// allocate counts size towards the allocation limit
+func (b *budget) allocate(size int) error {
+	b.allocated += size
+	if b.limits.MaxAllocation > 0 && b.allocated > b.limits.MaxAllocation {
+		return AllocationLimitError{Limit: b.limits.MaxAllocation}
+	}
+	return nil
+}
+
+// fits checks that size more could be allocated, for natives to call
+// before creating a value much larger than their arguments. It counts
+// nothing, as the value is counted once the native returns it.
+func (b *budget) fits(size int) error {
+	if b.limits.MaxAllocation > 0 && b.allocated+size > b.limits.MaxAllocation {
+		return AllocationLimitError{Limit: b.limits.MaxAllocation}
+	}
+	return nil
+}
+
+// nativeError converts an error returned by a native function to the
+// error to raise at token. Errors for exceeded limits become a halt,
+// which still stops the script, and exceptions from Lox code called
+// by the native carry on as they are.
+func nativeError(err error, token Token) error {
+	switch err.(type) {
+	case StepLimitError, CallDepthError, AllocationLimitError, CancelledError:
+		return halt{err: err, token: token}
+	case RuntimeError, Throw:
+		return err
+	}
+	return RuntimeError{token: token, msg: err.Error()}
+}
+
+// allocation returns the size of value, as counted towards the
+// allocation limit when it is created. The strings in a list or map
+// aren't counted, so natives which return new ones count them.
+func allocation(value any) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case *LoxList:
+		return len(v.elements)
+	case *LoxMap:
+		return v.Len()
+	}
+	return 0
+}
+
+// stmtToken returns a token from stmt, to report errors at which
+// aren't caused by any particular part of it
+func stmtToken(stmt Stmt) Token {
+	switch s := stmt.(type) {
+	case *ExpressionStmt:
+		return exprToken(s.Expr)
+	case *PrintStmt:
+		return exprToken(s.Expr)
+	case *VarStmt:
+		return s.Name
+	case *BlockStmt:
+		return s.brace
+	case *IfStmt:
+		return exprToken(s.expr)
+	case *WhileStmt:
+		return exprToken(s.expr)
+	case *FunctionStmt:
+		return s.name
+	case *ReturnStmt:
+		return s.keyword
+	case *ClassStmt:
+		return s.name
+	case *BreakStmt:
+		return s.keyword
+	case *ContinueStmt:
+		return s.keyword
+	case *ForInStmt:
+		return s.keyword
+	case *ThrowStmt:
+		return s.keyword
+	case *TryStmt:
+		return s.keyword
+	}
+	return Token{}
+}
+
+// exprToken returns the first token of expr
+func exprToken(expr Expr) Token {
+	switch e := expr.(type) {
+	case *Binary:
+		return exprToken(e.Left)
+	case *Unary:
+		return e.Op
+	case *Literal:
+		return e.token
+	case *Grouping:
+		return exprToken(e.Expr)
+	case *Variable:
+		return e.Name
+	case *Assign:
+		return e.Name
+	case *Logical:
+		return exprToken(e.left)
+	case *Call:
+		return exprToken(e.callee)
+	case *Get:
+		return exprToken(e.object)
+	case *Set:
+		return exprToken(e.object)
+	case *This:
+		return e.keyword
+	case *Super:
+		return e.keyword
+	case *ListLiteral:
+		return e.bracket
+	case *Index:
+		return exprToken(e.object)
+	case *IndexSet:
+		return exprToken(e.object)
+	case *Slice:
+		return exprToken(e.object)
+	case *MapLiteral:
+		return e.brace
+	case *Lambda:
+		return e.keyword
+	}
+	return Token{}
+}
@@ -0,0 +1,76 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestLimits runs a script which exceeds each limit on both backends,
+// inside a try statement which can't catch it
+func TestLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		limits Limits
+		ctx    context.Context
+		body   string
+		// A pointer to the error type the script is stopped with
+		target any
+	}{
+		{
+			name:   "steps",
+			limits: Limits{MaxSteps: 1000},
+			body:   `while (true) {}`,
+			target: new(StepLimitError),
+		},
+		{
+			name:   "call depth",
+			limits: Limits{MaxCallDepth: 50},
+			body:   `fun f() { f(); } f();`,
+			target: new(CallDepthError),
+		},
+		{
+			name:   "allocation",
+			limits: Limits{MaxAllocation: 1 << 20},
+			body:   `var s = "ab"; while (true) s = s + s;`,
+			target: new(AllocationLimitError),
+		},
+		{
+			name:   "cancelled",
+			ctx:    cancelled,
+			body:   `while (true) {}`,
+			target: new(CancelledError),
+		},
+	}
+	for _, tt := range tests {
+		for _, b := range backends {
+			t.Run(tt.name+"/"+b.name, func(t *testing.T) {
+				ctx := tt.ctx
+				if ctx == nil {
+					ctx = context.Background()
+				}
+				var stdout bytes.Buffer
+				vm := NewVM(&stdout, &stdout, WithBackend(b.backend), WithLimits(tt.limits))
+				source := `
+					try {
+						` + tt.body + `
+					} catch (e) {
+						print "caught";
+					} finally {
+						print "finally";
+					}`
+				_, err := vm.EvalContext(ctx, "test.lox", source)
+				if !errors.As(err, tt.target) {
+					t.Fatalf("returned %v, want a %T", err, tt.target)
+				}
+				if stdout.Len() != 0 {
+					t.Errorf("printed %q, want nothing", stdout.String())
+				}
+			})
+		}
+	}
+}
@@ -1,72 +1,229 @@
 package lox
 
 import (
-	"fmt"
-	"os"
 	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
 )
 
-var interpreter = Interpreter{env: NewEnvironment()}
-var hadError = false
-var hadRuntimeError = false
+// Value is any value that a Lox program can produce: nil, a float64,
+// string or bool, or one of the runtime types such as *LoxInstance
+type Value = any
 
-func RunFile(path string) {
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		os.Exit(1)
+// Backend is the way a VM runs programs. Both backends give
+// the same results.
+type Backend int
+
+const (
+	// TreeWalk runs programs by walking their syntax tree
+	TreeWalk Backend = iota
+	// Bytecode compiles programs to bytecode, and runs it on a
+	// stack machine. It is faster for long-running programs.
+	Bytecode
+)
+
+// VM is an independent instance of the Lox runtime. Each VM has its
+// own global environment and output streams, so several can be
+// embedded in the same process.
+type VM struct {
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	backend  Backend
+	optimise bool
+	limits   Limits
+	// What scripts may do outside of the VM
+	capabilities Capability
+	// Seeds math.random if set
+	seed *int64
+	// Only the one for the backend in use is set
+	interpreter *Interpreter
+	machine     *Machine
+}
+
+// Option configures a VM created by NewVM
+type Option func(*VM)
+
+// WithBackend selects the backend that the VM runs programs with.
+// The default is TreeWalk.
+func WithBackend(backend Backend) Option {
+	return func(vm *VM) {
+		vm.backend = backend
+	}
+}
+
+// WithOptimiser makes the VM rewrite programs with the Optimiser
+// before running them
+func WithOptimiser() Option {
+	return func(vm *VM) {
+		vm.optimise = true
 	}
-	run(string(bytes))
-	if hadError {
-		os.Exit(65)
+}
+
+// WithStdin sets the standard input that scripts read with
+// io.readLine. By default there is none, as if it were empty.
+func WithStdin(stdin io.Reader) Option {
+	return func(vm *VM) {
+		vm.stdin = stdin
+	}
+}
+
+func NewVM(stdout io.Writer, stderr io.Writer, opts ...Option) *VM {
+	vm := &VM{stdout: stdout, stderr: stderr}
+	for _, opt := range opts {
+		opt(vm)
+	}
+	seed := time.Now().UnixNano()
+	if vm.seed != nil {
+		seed = *vm.seed
+	}
+	h := &host{caps: vm.capabilities, rng: rand.New(rand.NewSource(seed)), stderr: stderr}
+	if vm.stdin != nil {
+		h.stdin = bufio.NewReader(vm.stdin)
+	}
+	if vm.backend == Bytecode {
+		vm.machine = NewMachine(stdout)
+		h.budget = &vm.machine.budget
+	} else {
+		vm.interpreter = NewInterpreter(stdout)
+		h.budget = &vm.interpreter.budget
+	}
+	h.budget.limits = vm.limits
+	defineBuiltins(vm.defineGlobal, h)
+	return vm
+}
+
+// Eval runs source in the VM, returning the value of the final
+// statement if it is an expression statement. Globals defined by
+// source persist between calls.
+func (vm *VM) Eval(source string) (Value, error) {
+	return vm.EvalNamed("<eval>", source)
+}
+
+// EvalNamed is like Eval, but name identifies the source in
+// diagnostics, e.g. a file path. Any error is returned as
+// Diagnostics; errors.As can be used to find a RuntimeError,
+// or the error for an exceeded limit such as StepLimitError.
+func (vm *VM) EvalNamed(name string, source string) (Value, error) {
+	return vm.EvalContext(context.Background(), name, source)
+}
+
+// EvalContext is like EvalNamed, but stops the script with a
+// CancelledError if ctx is cancelled or passes its deadline
+func (vm *VM) EvalContext(ctx context.Context, name string, source string) (Value, error) {
+	statements, err := parse(name, source)
+	if err != nil {
+		return nil, err
 	}
-	if hadRuntimeError {
-		os.Exit(70)
+	return diagnose(vm.run(ctx, statements))
+}
+
+// diagnose converts errors from running a script to Diagnostics
+func diagnose(value Value, err error) (Value, error) {
+	switch e := err.(type) {
+	case RuntimeError:
+		return nil, Diagnostics{e.Diagnostic()}
+	case halt:
+		return nil, Diagnostics{e.Diagnostic()}
 	}
+	return value, err
 }
 
-func RunPrompt() {
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print("> ")
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			os.Exit(1)
+// run resolves and runs the statements with the VM's backend
+func (vm *VM) run(ctx context.Context, statements []Stmt) (Value, error) {
+	if vm.optimise {
+		var err error
+		if statements, err = optimise(statements); err != nil {
+			return nil, err
+		}
+	}
+	if vm.machine == nil {
+		if err := NewResolver(vm.interpreter).Resolve(statements); err != nil {
+			return nil, err
 		}
-		run(line)
-		hadError = false
+		vm.interpreter.budget.start(ctx)
+		return vm.interpreter.Interpret(statements)
 	}
+
+	function, err := compile(statements)
+	if err != nil {
+		return nil, err
+	}
+	vm.machine.budget.start(ctx)
+	return vm.machine.Interpret(function)
 }
 
-func run(source string) {
-	scanner := NewScanner(source)
-	tokens := scanner.ScanTokens()
+// parse scans and parses source, reporting scanner and
+// parser errors together
+func parse(name string, source string) ([]Stmt, error) {
+	scanner := NewScanner(name, source)
+	tokens, scanErr := scanner.ScanTokens()
 
 	parser := NewParser(tokens)
-	statements, err := parser.Parse()
+	statements, parseErr := parser.Parse()
 
-	if hadError || err != nil { panic("Error while parsing") }
+	var errs Diagnostics
+	for _, err := range []error{scanErr, parseErr} {
+		if ds, ok := err.(Diagnostics); ok {
+			errs = append(errs, ds...)
+		}
+	}
+	return statements, errs.errorOrNil()
+}
 
-	interpreter.Interpret(statements)
+// optimise checks the statements before rewriting them, so
+// that errors in code the Optimiser drops are still reported
+func optimise(statements []Stmt) ([]Stmt, error) {
+	if err := NewResolver(nil).Resolve(statements); err != nil {
+		return nil, err
+	}
+	return NewOptimiser().Optimise(statements), nil
 }
 
-func Error(line int, msg string) {
-	report(line, "", msg)
+// compile checks and compiles statements for the Machine
+func compile(statements []Stmt) (*funcProto, error) {
+	// The compiler finds variables itself, so the
+	// resolver only checks the program
+	if err := NewResolver(nil).Resolve(statements); err != nil {
+		return nil, err
+	}
+	return NewCompiler().Compile(statements)
 }
 
-func ErrorOnToken(token Token, msg string) {
-	if token.Type == EOF {
-		report(token.Line, " at end", msg)
-	} else {
-		report(token.Line, " at '" + token.Lexeme + "'", msg)
+// DefineNative makes a Go function available to scripts run by
+// the VM as a global with the given name
+func (vm *VM) DefineNative(name string, arity int, fn NativeFn) {
+	vm.defineGlobal(name, NewNativeFunction(name, arity, fn))
+}
+
+// defineGlobal defines a global in the backend in use
+func (vm *VM) defineGlobal(name string, value any) {
+	if vm.machine != nil {
+		vm.machine.globals[name] = value
+		return
 	}
+	vm.interpreter.globals.Define(name, value)
 }
 
-func ReportRuntimeError(err RuntimeError) {
-	fmt.Println("[" + fmt.Sprint(err.token.Line) + "]", err.msg)
-	hadRuntimeError = true
+// ReportError writes an error returned by Eval to the VM's stderr,
+// showing the source line each diagnostic points at
+func (vm *VM) ReportError(err error) {
+	ReportError(vm.stderr, err)
 }
 
-func report(line int, where string, msg string) {
-	fmt.Println(fmt.Sprint("[line ", line, "] Error", where, ": ", msg))
-	hadError = true
+// ReportError writes an error returned by Eval or Compile to w,
+// showing the source line each diagnostic points at
+func ReportError(w io.Writer, err error) {
+	var ds Diagnostics
+	if errors.As(err, &ds) {
+		for _, d := range ds {
+			fmt.Fprint(w, d.Render())
+		}
+		return
+	}
+	fmt.Fprintln(w, err)
 }
@@ -0,0 +1,41 @@
+package lox
+
+type LoxClass struct {
+	name       string
+	superclass *LoxClass
+	methods    map[string]*LoxFunction
+}
+
+// findMethod looks up a method on the class, walking up
+// the superclass chain if it isn't found
+func (c *LoxClass) findMethod(name string) *LoxFunction {
+	if method, ok := c.methods[name]; ok {
+		return method
+	}
+	if c.superclass != nil {
+		return c.superclass.findMethod(name)
+	}
+	return nil
+}
+
+// call implements Callable
+func (c *LoxClass) call(i *Interpreter, paren Token, args []any) any {
+	instance := NewLoxInstance(c)
+	if initialiser := c.findMethod("init"); initialiser != nil {
+		initialiser.bind(instance).call(i, paren, args)
+	}
+	return instance
+}
+
+// arity implements Callable
+func (c *LoxClass) arity() int {
+	if initialiser := c.findMethod("init"); initialiser != nil {
+		return initialiser.arity()
+	}
+	return 0
+}
+
+// String implements Stringer
+func (c *LoxClass) String() string {
+	return c.name
+}
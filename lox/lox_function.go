@@ -3,28 +3,31 @@ package lox
 type LoxFunction struct {
 	decl *FunctionStmt
 	closure *Environment
+	isInitialiser bool
 }
 
 // call implements Callable
-func (f *LoxFunction) call(i *Interpreter, args []any) (retval any) {
+func (f *LoxFunction) call(i *Interpreter, paren Token, args []any) any {
 	funcEnv := NewEnclosedEnv(f.closure)
 	for i := 0; i < len(f.decl.params); i++ {
 		funcEnv.Define(f.decl.params[i].Lexeme, args[i])
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			// Recovering from return statement
-			// Exploit named return value
-			if ret, ok := r.(Return); ok {
-				retval = ret.value
-			} else {
-				panic(r)
-			}
-		}
-
-	}()
 	i.executeBlock(f.decl.body, funcEnv)
+	completion := i.completion
+	if completion == completeThrow {
+		// The exception carries on to the caller
+		return nil
+	}
+	i.completion = completeNormal
+	// An early 'return;' in an initialiser still
+	// produces the instance
+	if f.isInitialiser {
+		return f.closure.GetAt(0, 0)
+	}
+	if completion == completeReturn {
+		return i.completionValue
+	}
 	return nil
 }
 
@@ -33,7 +36,18 @@ func (f *LoxFunction) arity() int {
 	return len(f.decl.params)
 }
 
+// bind creates a copy of the method whose closure
+// has 'this' bound to the given instance
+func (f *LoxFunction) bind(instance *LoxInstance) *LoxFunction {
+	env := NewEnclosedEnv(f.closure)
+	env.Define("this", instance)
+	return &LoxFunction{decl: f.decl, closure: env, isInitialiser: f.isInitialiser}
+}
+
 // String implements Stringer
 func (f *LoxFunction) String() string {
+	if f.decl.name.Lexeme == "" {
+		return "<fn anonymous>"
+	}
 	return "<fn " + f.decl.name.Lexeme + ">"
 }
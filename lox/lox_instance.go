@@ -0,0 +1,45 @@
+package lox
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]any
+}
+
+func NewLoxInstance(class *LoxClass) *LoxInstance {
+	return &LoxInstance{class: class, fields: make(map[string]any)}
+}
+
+func (li *LoxInstance) Get(name Token) (any, error) {
+	// Fields shadow methods
+	if value, ok := li.fields[name.Lexeme]; ok {
+		return value, nil
+	}
+	if method := li.class.findMethod(name.Lexeme); method != nil {
+		return method.bind(li), nil
+	}
+	return nil, RuntimeError{token: name, msg: "Undefined property '" + name.Lexeme + "'."}
+}
+
+func (li *LoxInstance) Set(name Token, value any) {
+	li.fields[name.Lexeme] = value
+}
+
+// fieldMap implements object
+func (li *LoxInstance) fieldMap() map[string]any {
+	return li.fields
+}
+
+// instanceOf implements object
+func (li *LoxInstance) instanceOf(class any) bool {
+	for c := li.class; c != nil; c = c.superclass {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// String implements Stringer
+func (li *LoxInstance) String() string {
+	return li.class.name + " instance"
+}
@@ -0,0 +1,262 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type LoxList struct {
+	elements []any
+}
+
+func NewLoxList(elements []any) *LoxList {
+	return &LoxList{elements: elements}
+}
+
+// Elements returns the underlying slice of list elements
+func (l *LoxList) Elements() []any {
+	return l.elements
+}
+
+// toIndex validates an index into a sequence of length n,
+// counting back from the end for negative indices
+func toIndex(token Token, index any, n int) (int, error) {
+	f, ok := index.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, RuntimeError{token: token, msg: "Index must be an integer"}
+	}
+	// Checked before converting, as huge indices overflow an int
+	idx := f
+	if idx < 0 {
+		idx += float64(n)
+	}
+	if idx < 0 || idx >= float64(n) {
+		return 0, RuntimeError{token: token, msg: fmt.Sprintf("Index %v out of range for length %d", f, n)}
+	}
+	return int(idx), nil
+}
+
+// toSliceBound converts a slice bound for a sequence of length n,
+// clamping it to the sequence. A nil bound gives the default.
+func toSliceBound(token Token, bound any, n int, dflt int) (int, error) {
+	if bound == nil {
+		return dflt, nil
+	}
+	f, ok := bound.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, RuntimeError{token: token, msg: "Slice bounds must be integers"}
+	}
+	return clampBound(f, n), nil
+}
+
+// clampBound converts an integral slice bound for a sequence of
+// length n, counting back from the end if it is negative. It is
+// clamped before converting, as huge bounds overflow an int.
+func clampBound(bound float64, n int) int {
+	if bound < 0 {
+		bound += float64(n)
+	}
+	if bound < 0 {
+		return 0
+	}
+	if bound > float64(n) {
+		return n
+	}
+	return int(bound)
+}
+
+func (l *LoxList) Index(token Token, index any) (any, error) {
+	idx, err := toIndex(token, index, len(l.elements))
+	if err != nil {
+		return nil, err
+	}
+	return l.elements[idx], nil
+}
+
+func (l *LoxList) SetIndex(token Token, index any, value any) error {
+	idx, err := toIndex(token, index, len(l.elements))
+	if err != nil {
+		return err
+	}
+	l.elements[idx] = value
+	return nil
+}
+
+func (l *LoxList) Slice(token Token, start any, end any) (*LoxList, error) {
+	n := len(l.elements)
+	from, err := toSliceBound(token, start, n, 0)
+	if err != nil {
+		return nil, err
+	}
+	to, err := toSliceBound(token, end, n, n)
+	if err != nil {
+		return nil, err
+	}
+	if to < from {
+		to = from
+	}
+	elements := make([]any, to-from)
+	copy(elements, l.elements[from:to])
+	return NewLoxList(elements), nil
+}
+
+// Get looks up one of the built-in list methods, bound to the list
+func (l *LoxList) Get(c caller, name Token) (any, error) {
+	method := func(arity int, fn NativeFn) (any, error) {
+		return NewNativeFunction(name.Lexeme, arity, fn), nil
+	}
+	// Callbacks are called as if from the method name
+	call := func(callee any, args ...any) (any, error) {
+		return c.callValue(callee, name, args)
+	}
+
+	switch name.Lexeme {
+	case "len":
+		return method(0, func(args []any) (any, error) {
+			return float64(len(l.elements)), nil
+		})
+	case "push":
+		return method(1, func(args []any) (any, error) {
+			c.allocate(name, 1)
+			l.elements = append(l.elements, args[0])
+			return nil, nil
+		})
+	case "pop":
+		return method(0, func(args []any) (any, error) {
+			if len(l.elements) == 0 {
+				return nil, fmt.Errorf("Can't pop from an empty list")
+			}
+			last := l.elements[len(l.elements)-1]
+			l.elements = l.elements[:len(l.elements)-1]
+			return last, nil
+		})
+	case "insert":
+		return method(2, func(args []any) (any, error) {
+			// Inserting at the length appends
+			idx, err := toIndex(name, args[0], len(l.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			c.allocate(name, 1)
+			l.elements = append(l.elements, nil)
+			copy(l.elements[idx+1:], l.elements[idx:])
+			l.elements[idx] = args[1]
+			return nil, nil
+		})
+	case "remove":
+		return method(1, func(args []any) (any, error) {
+			idx, err := toIndex(name, args[0], len(l.elements))
+			if err != nil {
+				return nil, err
+			}
+			removed := l.elements[idx]
+			l.elements = append(l.elements[:idx], l.elements[idx+1:]...)
+			return removed, nil
+		})
+	case "map":
+		return method(1, func(args []any) (any, error) {
+			mapped := make([]any, len(l.elements))
+			for idx, element := range l.elements {
+				value, err := call(args[0], element)
+				if err != nil {
+					return nil, err
+				}
+				mapped[idx] = value
+			}
+			return NewLoxList(mapped), nil
+		})
+	case "filter":
+		return method(1, func(args []any) (any, error) {
+			var filtered []any
+			for _, element := range l.elements {
+				keep, err := call(args[0], element)
+				if err != nil {
+					return nil, err
+				}
+				if isTruthy(keep) {
+					filtered = append(filtered, element)
+				}
+			}
+			return NewLoxList(filtered), nil
+		})
+	case "reduce":
+		return method(2, func(args []any) (any, error) {
+			acc := args[1]
+			for _, element := range l.elements {
+				var err error
+				if acc, err = call(args[0], acc, element); err != nil {
+					return nil, err
+				}
+			}
+			return acc, nil
+		})
+	case "sort":
+		// Takes an optional comparison function, which should
+		// return a negative number if its first argument is smaller
+		return method(Variadic, func(args []any) (any, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("Expected at most 1 argument but received %d", len(args))
+			}
+			if len(args) == 1 {
+				// Sort a copy, as the comparison function could
+				// change the list. The sorted copy replaces it.
+				elements := append([]any(nil), l.elements...)
+				// The sort can't be stopped, so after an error
+				// the remaining comparisons do nothing
+				var err error
+				sort.SliceStable(elements, func(a, b int) bool {
+					if err != nil {
+						return false
+					}
+					var result any
+					if result, err = call(args[0], elements[a], elements[b]); err != nil {
+						return false
+					}
+					n, ok := result.(float64)
+					if !ok {
+						err = RuntimeError{token: name, msg: "Comparison function must return a number"}
+					}
+					return n < 0
+				})
+				if err != nil {
+					return nil, err
+				}
+				l.elements = elements
+				return nil, nil
+			}
+			return nil, sortDefault(l.elements)
+		})
+	}
+	return nil, RuntimeError{token: name, msg: "Undefined property '" + name.Lexeme + "'."}
+}
+
+// sortDefault sorts a list of only numbers or only strings
+func sortDefault(elements []any) error {
+	numbers, strs := true, true
+	for _, element := range elements {
+		_, isNum := element.(float64)
+		_, isStr := element.(string)
+		numbers = numbers && isNum
+		strs = strs && isStr
+	}
+	switch {
+	case numbers:
+		sort.SliceStable(elements, func(a, b int) bool { return elements[a].(float64) < elements[b].(float64) })
+	case strs:
+		sort.SliceStable(elements, func(a, b int) bool { return elements[a].(string) < elements[b].(string) })
+	default:
+		return fmt.Errorf("Can only sort lists of numbers or strings without a comparison function")
+	}
+	return nil
+}
+
+// String implements Stringer
+func (l *LoxList) String() string {
+	parts := make([]string, len(l.elements))
+	for idx, element := range l.elements {
+		parts[idx] = repr(element)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
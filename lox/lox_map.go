@@ -0,0 +1,133 @@
+package lox
+
+import "strings"
+
+type mapEntry struct {
+	key   any
+	value any
+}
+
+// LoxMap is a hash map which remembers the order that keys were
+// first inserted in. Keys are compared the same way as with '==',
+// so numbers, strings, booleans and nil compare by value, and
+// everything else by identity.
+type LoxMap struct {
+	entries []mapEntry
+	// Position of each key in entries
+	index map[any]int
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{index: make(map[any]int)}
+}
+
+func (m *LoxMap) Len() int {
+	return len(m.entries)
+}
+
+func (m *LoxMap) Has(key any) bool {
+	_, ok := m.index[key]
+	return ok
+}
+
+// Lookup returns the value for key, and whether it was present
+func (m *LoxMap) Lookup(key any) (any, bool) {
+	if idx, ok := m.index[key]; ok {
+		return m.entries[idx].value, true
+	}
+	return nil, false
+}
+
+func (m *LoxMap) Set(key any, value any) {
+	if idx, ok := m.index[key]; ok {
+		m.entries[idx].value = value
+		return
+	}
+	m.index[key] = len(m.entries)
+	m.entries = append(m.entries, mapEntry{key: key, value: value})
+}
+
+// Delete removes key from the map, reporting whether it was present
+func (m *LoxMap) Delete(key any) bool {
+	idx, ok := m.index[key]
+	if !ok {
+		return false
+	}
+	delete(m.index, key)
+	m.entries = append(m.entries[:idx], m.entries[idx+1:]...)
+	for i := idx; i < len(m.entries); i++ {
+		m.index[m.entries[i].key] = i
+	}
+	return true
+}
+
+// Keys returns the keys in insertion order
+func (m *LoxMap) Keys() []any {
+	keys := make([]any, len(m.entries))
+	for i, entry := range m.entries {
+		keys[i] = entry.key
+	}
+	return keys
+}
+
+// Values returns the values in the insertion order of their keys
+func (m *LoxMap) Values() []any {
+	values := make([]any, len(m.entries))
+	for i, entry := range m.entries {
+		values[i] = entry.value
+	}
+	return values
+}
+
+func (m *LoxMap) Index(token Token, key any) (any, error) {
+	value, ok := m.Lookup(key)
+	if !ok {
+		return nil, RuntimeError{token: token, msg: "Undefined key " + repr(key) + "."}
+	}
+	return value, nil
+}
+
+func (m *LoxMap) SetIndex(token Token, key any, value any) error {
+	m.Set(key, value)
+	return nil
+}
+
+// Get looks up one of the built-in map methods, bound to the map
+func (m *LoxMap) Get(name Token) (any, error) {
+	method := func(arity int, fn NativeFn) (any, error) {
+		return NewNativeFunction(name.Lexeme, arity, fn), nil
+	}
+
+	switch name.Lexeme {
+	case "len":
+		return method(0, func(args []any) (any, error) {
+			return float64(m.Len()), nil
+		})
+	case "keys":
+		return method(0, func(args []any) (any, error) {
+			return NewLoxList(m.Keys()), nil
+		})
+	case "values":
+		return method(0, func(args []any) (any, error) {
+			return NewLoxList(m.Values()), nil
+		})
+	case "has":
+		return method(1, func(args []any) (any, error) {
+			return m.Has(args[0]), nil
+		})
+	case "delete":
+		return method(1, func(args []any) (any, error) {
+			return m.Delete(args[0]), nil
+		})
+	}
+	return nil, RuntimeError{token: name, msg: "Undefined property '" + name.Lexeme + "'."}
+}
+
+// String implements Stringer
+func (m *LoxMap) String() string {
+	parts := make([]string, len(m.entries))
+	for i, entry := range m.entries {
+		parts[i] = repr(entry.key) + ": " + repr(entry.value)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
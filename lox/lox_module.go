@@ -0,0 +1,81 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+)
+
+// LoxModule is a namespace of native functions and constants, such
+// as os, whose members scripts use as properties
+type LoxModule struct {
+	name    string
+	members map[string]any
+}
+
+func newModule(name string) *LoxModule {
+	return &LoxModule{name: name, members: make(map[string]any)}
+}
+
+// native adds a native function to the module, named after it
+// in tracebacks, e.g. "os.getenv"
+func (m *LoxModule) native(name string, arity int, fn NativeFn) {
+	m.members[name] = NewNativeFunction(m.name+"."+name, arity, fn)
+}
+
+// Get looks up a member of the module
+func (m *LoxModule) Get(name Token) (any, error) {
+	value, ok := m.members[name.Lexeme]
+	if !ok {
+		return nil, RuntimeError{token: name, msg: "Undefined property '" + name.Lexeme + "'."}
+	}
+	return value, nil
+}
+
+// String implements Stringer
+func (m *LoxModule) String() string {
+	return "<module " + m.name + ">"
+}
+
+// The argument helpers check the type of argument idx of the native
+// function fn, returning an error for it to raise if it's wrong
+
+func argError(fn string, idx int, want string) error {
+	return fmt.Errorf("Argument %d of %s must be %s", idx+1, fn, want)
+}
+
+func stringArg(fn string, args []any, idx int) (string, error) {
+	s, ok := args[idx].(string)
+	if !ok {
+		return "", argError(fn, idx, "a string")
+	}
+	return s, nil
+}
+
+func numberArg(fn string, args []any, idx int) (float64, error) {
+	f, ok := args[idx].(float64)
+	if !ok {
+		return 0, argError(fn, idx, "a number")
+	}
+	return f, nil
+}
+
+// Integers beyond this can't all be represented as numbers
+const maxSafeInteger = 1 << 53
+
+// intArg only accepts integers up to maxSafeInteger in size,
+// so that they can be converted to an int without overflowing
+func intArg(fn string, args []any, idx int) (int, error) {
+	f, ok := args[idx].(float64)
+	if !ok || f != math.Trunc(f) || math.Abs(f) > maxSafeInteger {
+		return 0, argError(fn, idx, "an integer between -2^53 and 2^53")
+	}
+	return int(f), nil
+}
+
+func listArg(fn string, args []any, idx int) (*LoxList, error) {
+	l, ok := args[idx].(*LoxList)
+	if !ok {
+		return nil, argError(fn, idx, "a list")
+	}
+	return l, nil
+}
@@ -0,0 +1,734 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+)
+
+// Machine runs functions compiled by the Compiler. It is an
+// alternative to the Interpreter, with the same semantics.
+type Machine struct {
+	stdout io.Writer
+	stack  []any
+	sp     int
+	frames []machineFrame
+	// Exception handlers pushed by OP_TRY, innermost last
+	handlers []handler
+	// Upvalues which still refer to the stack, ordered by slot
+	openUpvalues []*upvalue
+	globals      map[string]any
+	// Class of the objects that runtime errors are
+	// caught as, defined by the prelude
+	errorClass *machineClass
+	// Resources used by the current run
+	budget budget
+}
+
+// machineFrame is a call in progress. Native functions get a
+// frame too, with a nil closure, so they appear in tracebacks.
+type machineFrame struct {
+	closure *closure
+	ip      int
+	// Stack slot of the callee, or 'this' for methods
+	base int
+	// The value that was called, for naming the frame
+	callee any
+	// The call site of calls made from Go. Otherwise it is
+	// the instruction the caller is at.
+	site *Token
+}
+
+type handler struct {
+	kind byte
+	// Frames in use when the handler was pushed
+	frameCount int
+	sp         int
+	ip         int
+}
+
+func NewMachine(stdout io.Writer) *Machine {
+	m := &Machine{stdout: stdout, stack: make([]any, 256), globals: make(map[string]any)}
+	m.loadPrelude()
+	return m
+}
+
+// DefineNative makes a Go function available to Lox code as a
+// global with the given name. Pass Variadic as the arity to
+// accept any number of arguments.
+func (m *Machine) DefineNative(name string, arity int, fn NativeFn) {
+	m.globals[name] = NewNativeFunction(name, arity, fn)
+}
+
+// Interpret runs a compiled program, returning the value it returns
+func (m *Machine) Interpret(function *funcProto) (value Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if h, ok := r.(halt); ok {
+				h.trace = m.stackTrace(h.token)
+				m.reset()
+				value, err = nil, h
+				return
+			}
+			exception, trace, ok := m.exception(r)
+			if !ok {
+				panic(r)
+			}
+			if trace == nil {
+				trace = m.traceOf(exception)
+			}
+			re, ok := exception.(RuntimeError)
+			if !ok {
+				re = uncaught(exception.(Throw), m.errorClass)
+			}
+			re.trace = trace
+			m.reset()
+			value, err = nil, re
+		}
+	}()
+	script := &closure{function: function}
+	m.push(script)
+	m.frames = append(m.frames, machineFrame{closure: script, base: m.sp - 1})
+	m.run(0)
+	return m.pop(), nil
+}
+
+// reset leaves the machine empty for the next call
+func (m *Machine) reset() {
+	m.sp = 0
+	m.frames = m.frames[:0]
+	m.handlers = m.handlers[:0]
+	m.openUpvalues = nil
+}
+
+// allocate implements caller
+func (m *Machine) allocate(token Token, size int) {
+	if err := m.budget.allocate(size); err != nil {
+		panic(halt{err: err, token: token})
+	}
+}
+
+func (m *Machine) push(value any) {
+	if m.sp == len(m.stack) {
+		m.stack = append(m.stack, make([]any, len(m.stack))...)
+	}
+	m.stack[m.sp] = value
+	m.sp++
+}
+
+func (m *Machine) pop() any {
+	m.sp--
+	return m.stack[m.sp]
+}
+
+func (m *Machine) peek() any {
+	return m.stack[m.sp-1]
+}
+
+// run executes instructions until the number of frames drops back to
+// base. A handler can only catch an exception if it was pushed above
+// base, as Go code below that is waiting for a value.
+func (m *Machine) run(base int) {
+	for !m.execute(base) {
+	}
+}
+
+// execute runs instructions, returning true once the number of frames
+// drops to base, or false if an exception was caught by a handler
+func (m *Machine) execute(base int) (done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !m.catch(r, base) {
+				panic(r)
+			}
+			done = false
+		}
+	}()
+
+	frame := &m.frames[len(m.frames)-1]
+	chunk := frame.closure.function.chunk
+	// Reload the frame after anything which can call back into Lox,
+	// as that can grow the frames
+	reload := func() {
+		frame = &m.frames[len(m.frames)-1]
+		chunk = frame.closure.function.chunk
+	}
+
+	for {
+		code := chunk.code
+		start := frame.ip
+		op := OpCode(code[start])
+		if err := m.budget.step(); err != nil {
+			panic(halt{err: err, token: chunk.token(start)})
+		}
+		frame.ip++
+
+		switch op {
+		case OP_CONSTANT:
+			m.push(chunk.constants[chunk.readShort(frame.ip)])
+			frame.ip += 2
+		case OP_NIL:
+			m.push(nil)
+		case OP_TRUE:
+			m.push(true)
+		case OP_FALSE:
+			m.push(false)
+		case OP_POP:
+			m.sp--
+		case OP_GET_LOCAL:
+			m.push(m.stack[frame.base+int(code[frame.ip])])
+			frame.ip++
+		case OP_SET_LOCAL:
+			m.stack[frame.base+int(code[frame.ip])] = m.peek()
+			frame.ip++
+		case OP_GET_GLOBAL:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			value, ok := m.globals[name]
+			if !ok {
+				m.error(chunk, start, "Undefined variable '"+name+"'.")
+			}
+			m.push(value)
+		case OP_DEFINE_GLOBAL:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			m.globals[name] = m.pop()
+		case OP_SET_GLOBAL:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			if _, ok := m.globals[name]; !ok {
+				m.error(chunk, start, "Undefined variable '"+name+"'.")
+			}
+			m.globals[name] = m.peek()
+		case OP_GET_UPVALUE:
+			uv := frame.closure.upvalues[code[frame.ip]]
+			frame.ip++
+			if uv.open {
+				m.push(m.stack[uv.slot])
+			} else {
+				m.push(uv.closed)
+			}
+		case OP_SET_UPVALUE:
+			uv := frame.closure.upvalues[code[frame.ip]]
+			frame.ip++
+			if uv.open {
+				m.stack[uv.slot] = m.peek()
+			} else {
+				uv.closed = m.peek()
+			}
+		case OP_GET_PROPERTY:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			m.stack[m.sp-1] = m.getProperty(chunk, start, m.peek(), name)
+		case OP_SET_PROPERTY:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			value := m.pop()
+			instance, ok := m.peek().(*machineInstance)
+			if !ok {
+				m.error(chunk, start, "Only instances have fields")
+			}
+			instance.fields[name] = value
+			m.stack[m.sp-1] = value
+		case OP_GET_SUPER:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			superclass, ok := m.pop().(*machineClass)
+			if !ok {
+				m.malformed(chunk, start)
+			}
+			method, ok := superclass.methods[name]
+			if !ok {
+				m.error(chunk, start, "Undefined property '"+name+"'.")
+			}
+			m.stack[m.sp-1] = &boundMethod{receiver: m.peek(), method: method}
+		case OP_EQUAL:
+			right := m.pop()
+			m.stack[m.sp-1] = isEqual(m.peek(), right)
+		case OP_NOT_EQUAL:
+			right := m.pop()
+			m.stack[m.sp-1] = !isEqual(m.peek(), right)
+		case OP_GREATER:
+			left, right := m.numberOperands(chunk, start)
+			m.push(left > right)
+		case OP_GREATER_EQUAL:
+			left, right := m.numberOperands(chunk, start)
+			m.push(left >= right)
+		case OP_LESS:
+			left, right := m.numberOperands(chunk, start)
+			m.push(left < right)
+		case OP_LESS_EQUAL:
+			left, right := m.numberOperands(chunk, start)
+			m.push(left <= right)
+		case OP_ADD:
+			switch left := m.stack[m.sp-2].(type) {
+			case float64:
+				if right, ok := m.stack[m.sp-1].(float64); ok {
+					m.sp--
+					m.stack[m.sp-1] = left + right
+					continue
+				}
+			case string:
+				if right, ok := m.stack[m.sp-1].(string); ok {
+					m.allocate(chunk.token(start), len(left)+len(right))
+					m.sp--
+					m.stack[m.sp-1] = left + right
+					continue
+				}
+			}
+			m.error(chunk, start, "Operands must be two numbers or two strings")
+		case OP_SUBTRACT:
+			left, right := m.numberOperands(chunk, start)
+			m.push(left - right)
+		case OP_MULTIPLY:
+			left, right := m.numberOperands(chunk, start)
+			m.push(left * right)
+		case OP_DIVIDE:
+			left, right := m.numberOperands(chunk, start)
+			m.push(left / right)
+		case OP_NOT:
+			m.stack[m.sp-1] = !isTruthy(m.peek())
+		case OP_NEGATE:
+			value, ok := m.peek().(float64)
+			if !ok {
+				m.error(chunk, start, "Operand must be a number")
+			}
+			m.stack[m.sp-1] = -value
+		case OP_PRINT:
+			fmt.Fprintln(m.stdout, stringify(m.pop()))
+		case OP_JUMP:
+			frame.ip += 2 + chunk.readShort(frame.ip)
+		case OP_JUMP_IF_FALSE:
+			offset := chunk.readShort(frame.ip)
+			frame.ip += 2
+			if !isTruthy(m.peek()) {
+				frame.ip += offset
+			}
+		case OP_LOOP:
+			frame.ip += 2 - chunk.readShort(frame.ip)
+		case OP_CALL:
+			argCount := int(code[frame.ip])
+			frame.ip++
+			m.call(m.stack[m.sp-1-argCount], argCount, nil)
+			reload()
+		case OP_CLOSURE:
+			function := chunk.constants[chunk.readShort(frame.ip)].(*funcProto)
+			frame.ip += 2
+			cl := &closure{function: function, upvalues: make([]*upvalue, function.upvalueCount)}
+			for idx := range cl.upvalues {
+				isLocal, index := code[frame.ip], int(code[frame.ip+1])
+				frame.ip += 2
+				if isLocal == 1 {
+					cl.upvalues[idx] = m.captureUpvalue(frame.base + index)
+				} else {
+					cl.upvalues[idx] = frame.closure.upvalues[index]
+				}
+			}
+			m.push(cl)
+		case OP_CLOSE_UPVALUE:
+			m.closeUpvalues(m.sp - 1)
+			m.sp--
+		case OP_RETURN:
+			result := m.pop()
+			m.closeUpvalues(frame.base)
+			m.sp = frame.base
+			m.frames = m.frames[:len(m.frames)-1]
+			m.push(result)
+			if len(m.frames) == base {
+				return true
+			}
+			reload()
+		case OP_CLASS:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			m.push(&machineClass{name: name, methods: make(map[string]*closure)})
+		case OP_CHECK_SUPERCLASS:
+			if _, ok := m.peek().(*machineClass); !ok {
+				m.error(chunk, start, "Superclass must be a class")
+			}
+		case OP_INHERIT:
+			subclass, okSub := m.pop().(*machineClass)
+			superclass, okSuper := m.peek().(*machineClass)
+			if !(okSub && okSuper) {
+				m.malformed(chunk, start)
+			}
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+			subclass.superclass = superclass
+		case OP_METHOD:
+			name := chunk.constants[chunk.readShort(frame.ip)].(string)
+			frame.ip += 2
+			method, okMethod := m.pop().(*closure)
+			class, okClass := m.peek().(*machineClass)
+			if !(okMethod && okClass) {
+				m.malformed(chunk, start)
+			}
+			class.methods[name] = method
+		case OP_LIST:
+			count := chunk.readShort(frame.ip)
+			frame.ip += 2
+			m.allocate(chunk.token(start), count)
+			elements := make([]any, count)
+			copy(elements, m.stack[m.sp-count:m.sp])
+			m.sp -= count
+			m.push(NewLoxList(elements))
+		case OP_MAP:
+			count := chunk.readShort(frame.ip)
+			frame.ip += 2
+			m.allocate(chunk.token(start), count)
+			entries := NewLoxMap()
+			for idx := m.sp - 2*count; idx < m.sp; idx += 2 {
+				entries.Set(m.stack[idx], m.stack[idx+1])
+			}
+			m.sp -= 2 * count
+			m.push(entries)
+		case OP_INDEX:
+			index := m.pop()
+			collection, ok := m.peek().(indexable)
+			if !ok {
+				m.error(chunk, start, "Only lists and maps can be indexed")
+			}
+			value, err := collection.Index(chunk.token(start), index)
+			if err != nil {
+				panic(err)
+			}
+			m.stack[m.sp-1] = value
+		case OP_SET_INDEX:
+			value := m.pop()
+			index := m.pop()
+			collection, ok := m.peek().(indexable)
+			if !ok {
+				m.error(chunk, start, "Only lists and maps can be indexed")
+			}
+			if entries, ok := collection.(*LoxMap); ok && !entries.Has(index) {
+				m.allocate(chunk.token(start), 1)
+			}
+			if err := collection.SetIndex(chunk.token(start), index, value); err != nil {
+				panic(err)
+			}
+			m.stack[m.sp-1] = value
+		case OP_SLICE:
+			end := m.pop()
+			begin := m.pop()
+			list, ok := m.peek().(*LoxList)
+			if !ok {
+				m.error(chunk, start, "Only lists can be sliced")
+			}
+			slice, err := list.Slice(chunk.token(start), begin, end)
+			if err != nil {
+				panic(err)
+			}
+			m.allocate(chunk.token(start), len(slice.elements))
+			m.stack[m.sp-1] = slice
+		case OP_ITER:
+			next, err := newIterator(m, chunk.token(start), m.peek())
+			if err != nil {
+				panic(err)
+			}
+			reload()
+			m.stack[m.sp-1] = &machineIterator{next: next}
+		case OP_FOR_ITER:
+			offset := chunk.readShort(frame.ip)
+			frame.ip += 2
+			it, ok := m.peek().(*machineIterator)
+			if !ok {
+				m.malformed(chunk, start)
+			}
+			value, ok, err := it.next()
+			if err != nil {
+				panic(err)
+			}
+			reload()
+			if ok {
+				m.push(value)
+			} else {
+				frame.ip += offset
+			}
+		case OP_THROW:
+			value := m.pop()
+			token := chunk.token(start)
+			markThrown(value, m.errorClass, token)
+			panic(Throw{token: token, value: value})
+		case OP_TRY:
+			kind := code[frame.ip]
+			offset := chunk.readShort(frame.ip + 1)
+			frame.ip += 3
+			m.handlers = append(m.handlers, handler{kind: kind, frameCount: len(m.frames), sp: m.sp, ip: frame.ip + offset})
+		case OP_END_TRY:
+			m.handlers = m.handlers[:len(m.handlers)-1]
+		case OP_RETHROW:
+			pending, ok := m.pop().(*pendingException)
+			if !ok {
+				m.malformed(chunk, start)
+			}
+			panic(pending)
+		case OP_UNWIND:
+			count, keep := int(code[frame.ip]), code[frame.ip+1] == 1
+			frame.ip += 2
+			top := m.peek()
+			m.closeUpvalues(frame.base + count)
+			m.sp = frame.base + count
+			if keep {
+				m.push(top)
+			}
+		default:
+			panic(fmt.Sprintf("unknown opcode %d", op))
+		}
+	}
+}
+
+// error raises a runtime error at the instruction at offset
+func (m *Machine) error(chunk *Chunk, offset int, msg string) {
+	panic(RuntimeError{token: chunk.token(offset), msg: msg})
+}
+
+// malformed raises an error for an operand of the wrong type, which
+// compiled code never has but a program read from a file could
+func (m *Machine) malformed(chunk *Chunk, offset int) {
+	m.error(chunk, offset, "Malformed compiled program")
+}
+
+func (m *Machine) numberOperands(chunk *Chunk, offset int) (float64, float64) {
+	left, okLeft := m.stack[m.sp-2].(float64)
+	right, okRight := m.stack[m.sp-1].(float64)
+	if !(okLeft && okRight) {
+		m.error(chunk, offset, "Operands must be numbers")
+	}
+	m.sp -= 2
+	return left, right
+}
+
+func (m *Machine) getProperty(chunk *Chunk, offset int, object any, name string) any {
+	switch object := object.(type) {
+	case *machineInstance:
+		// Fields shadow methods
+		if value, ok := object.fields[name]; ok {
+			return value
+		}
+		if method, ok := object.class.methods[name]; ok {
+			return &boundMethod{receiver: object, method: method}
+		}
+		m.error(chunk, offset, "Undefined property '"+name+"'.")
+	case *LoxList:
+		return check(object.Get(m, chunk.token(offset)))
+	case *LoxMap:
+		return check(object.Get(chunk.token(offset)))
+	case *LoxModule:
+		return check(object.Get(chunk.token(offset)))
+	}
+	m.error(chunk, offset, "Only instances have properties")
+	return nil
+}
+
+// callSite returns the token that errors in calling a function
+// are reported at
+func (m *Machine) callSite(site *Token) Token {
+	if site != nil {
+		return *site
+	}
+	caller := m.frames[len(m.frames)-1]
+	return caller.closure.function.chunk.token(caller.ip - 1)
+}
+
+// call calls callee with the argCount values above it on the stack.
+// Closures get a new frame, while other values are called at once,
+// leaving their result in place of callee.
+func (m *Machine) call(callee any, argCount int, site *Token) {
+	switch callee := callee.(type) {
+	case *closure:
+		m.callClosure(callee, callee, argCount, site)
+	case *boundMethod:
+		m.stack[m.sp-1-argCount] = callee.receiver
+		m.callClosure(callee.method, callee, argCount, site)
+	case *machineClass:
+		m.stack[m.sp-1-argCount] = &machineInstance{class: callee, fields: make(map[string]any)}
+		if initialiser, ok := callee.methods["init"]; ok {
+			m.callClosure(initialiser, callee, argCount, site)
+		} else if argCount != 0 {
+			m.arityError(site, 0, argCount)
+		}
+	case *NativeFunction:
+		if callee.arityN != Variadic && argCount != callee.arityN {
+			m.arityError(site, callee.arityN, argCount)
+		}
+		args := make([]any, argCount)
+		copy(args, m.stack[m.sp-argCount:m.sp])
+		token := m.callSite(site)
+		if err := m.budget.call(len(m.frames)); err != nil {
+			panic(halt{err: err, token: token})
+		}
+		// The frame is left for the traceback if the call fails
+		m.frames = append(m.frames, machineFrame{callee: callee, site: site})
+		value, err := callNative(m, callee, token, args)
+		if err != nil {
+			panic(err)
+		}
+		m.frames = m.frames[:len(m.frames)-1]
+		m.sp -= argCount
+		m.stack[m.sp-1] = value
+	default:
+		panic(RuntimeError{token: m.callSite(site), msg: "Not a callable expression"})
+	}
+}
+
+func (m *Machine) callClosure(cl *closure, callee any, argCount int, site *Token) {
+	if argCount != cl.function.arity {
+		m.arityError(site, cl.function.arity, argCount)
+	}
+	// The script's own frame doesn't count as a call
+	if err := m.budget.call(len(m.frames)); err != nil {
+		panic(halt{err: err, token: m.callSite(site)})
+	}
+	m.frames = append(m.frames, machineFrame{closure: cl, base: m.sp - 1 - argCount, callee: callee, site: site})
+}
+
+func (m *Machine) arityError(site *Token, arity int, argCount int) {
+	msg := fmt.Sprintf("Expected %d argument but received %d", arity, argCount)
+	panic(RuntimeError{token: m.callSite(site), msg: msg})
+}
+
+// check panics with err if there is one, as the machine raises errors
+// by panicking, and otherwise returns value
+func check(value any, err error) any {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// callValue implements caller. Exceptions unwind as panics on this
+// backend, so it never returns an error.
+func (m *Machine) callValue(callee any, token Token, args []any) (any, error) {
+	base := len(m.frames)
+	m.push(callee)
+	for _, arg := range args {
+		m.push(arg)
+	}
+	m.call(callee, len(args), &token)
+	if len(m.frames) > base {
+		m.run(base)
+	}
+	return m.pop(), nil
+}
+
+// invoke implements caller
+func (m *Machine) invoke(object any, token Token, name string, args []any) (any, bool, error) {
+	instance, ok := object.(*machineInstance)
+	if !ok {
+		return nil, false, nil
+	}
+	method, ok := instance.class.methods[name]
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := m.callValue(&boundMethod{receiver: instance, method: method}, token, args)
+	return value, true, err
+}
+
+func (m *Machine) captureUpvalue(slot int) *upvalue {
+	idx := len(m.openUpvalues)
+	for idx > 0 && m.openUpvalues[idx-1].slot >= slot {
+		if m.openUpvalues[idx-1].slot == slot {
+			return m.openUpvalues[idx-1]
+		}
+		idx--
+	}
+	uv := &upvalue{slot: slot, open: true}
+	m.openUpvalues = append(m.openUpvalues, nil)
+	copy(m.openUpvalues[idx+1:], m.openUpvalues[idx:])
+	m.openUpvalues[idx] = uv
+	return uv
+}
+
+// closeUpvalues moves the values of the upvalues for slot
+// and above off the stack
+func (m *Machine) closeUpvalues(slot int) {
+	n := len(m.openUpvalues)
+	for n > 0 && m.openUpvalues[n-1].slot >= slot {
+		uv := m.openUpvalues[n-1]
+		uv.closed, uv.open = m.stack[uv.slot], false
+		n--
+	}
+	m.openUpvalues = m.openUpvalues[:n]
+}
+
+// exception unpacks a value recovered from a panic, returning the
+// RuntimeError or Throw, or false if it isn't one. The traceback is
+// only returned if it was saved when the exception was first caught.
+func (m *Machine) exception(r any) (exception any, trace []StackFrame, ok bool) {
+	switch e := r.(type) {
+	case *pendingException:
+		return e.err, e.trace, true
+	case RuntimeError, Throw:
+		return e, nil, true
+	}
+	return nil, nil, false
+}
+
+// traceOf returns the traceback of the calls in progress, ending
+// where exception was raised
+func (m *Machine) traceOf(exception any) []StackFrame {
+	if t, ok := exception.(Throw); ok {
+		return m.stackTrace(t.token)
+	}
+	return m.stackTrace(exception.(RuntimeError).token)
+}
+
+// catch passes an exception to the innermost handler, reporting
+// false if there isn't one that was pushed within run(base)
+func (m *Machine) catch(r any, base int) bool {
+	if len(m.handlers) == 0 || m.handlers[len(m.handlers)-1].frameCount <= base {
+		return false
+	}
+	exception, trace, ok := m.exception(r)
+	if !ok {
+		return false
+	}
+	h := m.handlers[len(m.handlers)-1]
+	m.handlers = m.handlers[:len(m.handlers)-1]
+
+	var value any
+	switch e := exception.(type) {
+	case Throw:
+		value = e.value
+	case RuntimeError:
+		value = m.errorObject(e)
+	}
+	if h.kind == handlerFinally {
+		// Keep the traceback of where the exception was raised,
+		// in case it is uncaught after being rethrown
+		if trace == nil {
+			trace = m.traceOf(exception)
+		}
+		value = &pendingException{err: exception, trace: trace}
+	}
+
+	// Discard the frames of the calls that were unwound
+	m.frames = m.frames[:h.frameCount]
+	m.closeUpvalues(h.sp)
+	m.sp = h.sp
+	m.push(value)
+	m.frames[len(m.frames)-1].ip = h.ip
+	return true
+}
+
+// errorObject converts a RuntimeError to an Error instance
+func (m *Machine) errorObject(err RuntimeError) *machineInstance {
+	return &machineInstance{class: m.errorClass, fields: errorFields(err)}
+}
+
+// stackTrace returns the traceback of the calls in progress,
+// ending at token
+func (m *Machine) stackTrace(token Token) []StackFrame {
+	frames := make([]callFrame, 0, len(m.frames))
+	for idx := 1; idx < len(m.frames); idx++ {
+		site := m.frames[idx].site
+		if site == nil {
+			caller := m.frames[idx-1]
+			token := caller.closure.function.chunk.token(caller.ip - 1)
+			site = &token
+		}
+		frames = append(frames, callFrame{name: callableName(m.frames[idx].callee), site: *site})
+	}
+	return stackTrace(frames, token)
+}
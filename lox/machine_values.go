@@ -0,0 +1,103 @@
+package lox
+
+// funcProto is a function compiled to bytecode, before it
+// has closed over any variables
+type funcProto struct {
+	name         string
+	arity        int
+	upvalueCount int
+	chunk        *Chunk
+}
+
+// String implements Stringer
+func (f *funcProto) String() string {
+	if f.name == "" {
+		return "<fn anonymous>"
+	}
+	return "<fn " + f.name + ">"
+}
+
+// upvalue is a variable captured by a closure. While open, it refers
+// to a slot on the Machine's stack; once that slot is popped, the
+// value moves into the upvalue itself.
+type upvalue struct {
+	slot   int
+	open   bool
+	closed any
+}
+
+type closure struct {
+	function *funcProto
+	upvalues []*upvalue
+}
+
+// String implements Stringer
+func (c *closure) String() string {
+	return c.function.String()
+}
+
+type machineClass struct {
+	name       string
+	superclass *machineClass
+	// Inherited methods are copied down when the class is created
+	methods map[string]*closure
+}
+
+func (c *machineClass) arity() int {
+	if initialiser, ok := c.methods["init"]; ok {
+		return initialiser.function.arity
+	}
+	return 0
+}
+
+// String implements Stringer
+func (c *machineClass) String() string {
+	return c.name
+}
+
+type machineInstance struct {
+	class  *machineClass
+	fields map[string]any
+}
+
+// fieldMap implements object
+func (i *machineInstance) fieldMap() map[string]any {
+	return i.fields
+}
+
+// instanceOf implements object
+func (i *machineInstance) instanceOf(class any) bool {
+	for c := i.class; c != nil; c = c.superclass {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// String implements Stringer
+func (i *machineInstance) String() string {
+	return i.class.name + " instance"
+}
+
+type boundMethod struct {
+	receiver any
+	method   *closure
+}
+
+// String implements Stringer
+func (b *boundMethod) String() string {
+	return b.method.String()
+}
+
+// machineIterator holds the state of a for-in loop
+type machineIterator struct {
+	next func() (any, bool, error)
+}
+
+// pendingException holds an exception while a finally block
+// runs, so that it can be rethrown afterwards
+type pendingException struct {
+	err   any
+	trace []StackFrame
+}
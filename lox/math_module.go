@@ -0,0 +1,135 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+)
+
+// WithRandomSeed seeds the generator behind math.random, so that runs
+// are reproducible. By default it is seeded from the time.
+func WithRandomSeed(seed int64) Option {
+	return func(vm *VM) {
+		vm.seed = &seed
+	}
+}
+
+// mathModule wraps Go's math package, and provides pseudo-random
+// numbers from the generator of h
+func mathModule(h *host) *LoxModule {
+	m := newModule("math")
+
+	m.members["pi"] = math.Pi
+	m.members["e"] = math.E
+	m.members["inf"] = math.Inf(1)
+	m.members["nan"] = math.NaN()
+
+	// Functions of one number
+	unary := func(name string, fn func(x float64) float64) {
+		m.native(name, 1, func(args []any) (any, error) {
+			x, err := numberArg("math."+name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return fn(x), nil
+		})
+	}
+	unary("floor", math.Floor)
+	unary("ceil", math.Ceil)
+	// Rounds halves away from zero
+	unary("round", math.Round)
+	unary("abs", math.Abs)
+	unary("sqrt", math.Sqrt)
+	unary("sin", math.Sin)
+	unary("cos", math.Cos)
+	unary("tan", math.Tan)
+	unary("asin", math.Asin)
+	unary("acos", math.Acos)
+	unary("atan", math.Atan)
+	// Natural logarithm
+	unary("log", math.Log)
+	unary("log2", math.Log2)
+	unary("log10", math.Log10)
+	unary("exp", math.Exp)
+
+	// Functions of two numbers
+	binary := func(name string, fn func(x float64, y float64) float64) {
+		m.native(name, 2, func(args []any) (any, error) {
+			x, err := numberArg("math."+name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			y, err := numberArg("math."+name, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return fn(x, y), nil
+		})
+	}
+	binary("pow", math.Pow)
+	binary("atan2", math.Atan2)
+
+	// Functions of one or more numbers
+	fold := func(name string, fn func(x float64, y float64) float64) {
+		m.native(name, Variadic, func(args []any) (any, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("Expected at least 1 argument but received 0")
+			}
+			result, err := numberArg("math."+name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			for idx := 1; idx < len(args); idx++ {
+				x, err := numberArg("math."+name, args, idx)
+				if err != nil {
+					return nil, err
+				}
+				result = fn(result, x)
+			}
+			return result, nil
+		})
+	}
+	fold("min", math.Min)
+	fold("max", math.Max)
+
+	m.native("isNaN", 1, func(args []any) (any, error) {
+		x, err := numberArg("math.isNaN", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return math.IsNaN(x), nil
+	})
+
+	// A number from 0 up to but not including 1
+	m.native("random", 0, func(args []any) (any, error) {
+		return h.rng.Float64(), nil
+	})
+
+	// An integer from lo up to but not including hi. As both are at
+	// most 2^53 in size, hi-lo can't overflow.
+	m.native("randomInt", 2, func(args []any) (any, error) {
+		lo, err := intArg("math.randomInt", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := intArg("math.randomInt", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if hi <= lo {
+			return nil, fmt.Errorf("Range of math.randomInt is empty")
+		}
+		return float64(int64(lo) + h.rng.Int63n(int64(hi)-int64(lo))), nil
+	})
+
+	// Restarts the sequence of random numbers
+	m.native("seed", 1, func(args []any) (any, error) {
+		seed, err := intArg("math.seed", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		h.rng.Seed(int64(seed))
+		return nil, nil
+	})
+
+	return m
+}
@@ -0,0 +1,57 @@
+package lox
+
+// NativeFn is the signature of a Go function that can be called
+// from Lox. Returning a non-nil error raises a runtime error at
+// the call site.
+type NativeFn func(args []any) (any, error)
+
+// Variadic can be given as the arity of a native function
+// to accept any number of arguments
+const Variadic = -1
+
+type NativeFunction struct {
+	name   string
+	arityN int
+	fn     NativeFn
+}
+
+func NewNativeFunction(name string, arity int, fn NativeFn) *NativeFunction {
+	return &NativeFunction{name: name, arityN: arity, fn: fn}
+}
+
+// call implements Callable
+func (n *NativeFunction) call(i *Interpreter, paren Token, args []any) any {
+	value, err := callNative(i, n, paren, args)
+	if err != nil {
+		i.raise(err)
+	}
+	return value
+}
+
+// callNative calls n on behalf of either backend, returning the error
+// to raise at token if it fails. Errors for exceeded limits stop the
+// script as a halt instead.
+func callNative(c caller, n *NativeFunction, token Token, args []any) (any, error) {
+	value, err := n.fn(args)
+	if err != nil {
+		err = nativeError(err, token)
+		if h, ok := err.(halt); ok {
+			panic(h)
+		}
+		return nil, err
+	}
+	// Native functions can't count what they allocate, so
+	// whatever they return is counted as new
+	c.allocate(token, allocation(value))
+	return value, nil
+}
+
+// arity implements Callable
+func (n *NativeFunction) arity() int {
+	return n.arityN
+}
+
+// String implements Stringer
+func (n *NativeFunction) String() string {
+	return "<native fn " + n.name + ">"
+}
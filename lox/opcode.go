@@ -0,0 +1,132 @@
+package lox
+
+import "fmt"
+
+// OpCode is a single bytecode instruction. Operands follow the opcode
+// in the chunk; the comment on each gives their sizes in bytes.
+type OpCode byte
+
+const (
+	OP_CONSTANT OpCode = iota // constant index (2)
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_LOCAL     // slot (1)
+	OP_SET_LOCAL     // slot (1)
+	OP_GET_GLOBAL    // name constant (2)
+	OP_DEFINE_GLOBAL // name constant (2)
+	OP_SET_GLOBAL    // name constant (2)
+	OP_GET_UPVALUE   // upvalue index (1)
+	OP_SET_UPVALUE   // upvalue index (1)
+	OP_GET_PROPERTY  // name constant (2)
+	OP_SET_PROPERTY  // name constant (2)
+	OP_GET_SUPER     // name constant (2)
+	OP_EQUAL
+	OP_NOT_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NOT
+	OP_NEGATE
+	OP_PRINT
+	OP_JUMP          // forward offset (2)
+	OP_JUMP_IF_FALSE // forward offset (2)
+	OP_LOOP          // backward offset (2)
+	OP_CALL          // argument count (1)
+	OP_CLOSURE       // function constant (2), then (is local (1), index (1)) per upvalue
+	OP_CLOSE_UPVALUE
+	OP_RETURN
+	OP_CLASS // name constant (2)
+	OP_CHECK_SUPERCLASS
+	OP_INHERIT
+	OP_METHOD // name constant (2)
+	OP_LIST   // element count (2)
+	OP_MAP    // entry count (2)
+	OP_INDEX
+	OP_SET_INDEX
+	OP_SLICE
+	OP_ITER
+	OP_FOR_ITER // forward offset to the end of the loop (2)
+	OP_THROW
+	OP_TRY // handler kind (1), forward offset to the handler (2)
+	OP_END_TRY
+	OP_RETHROW
+	OP_UNWIND // local count (1), keep top of stack (1)
+)
+
+var opNames = [...]string{
+	OP_CONSTANT:         "OP_CONSTANT",
+	OP_NIL:              "OP_NIL",
+	OP_TRUE:             "OP_TRUE",
+	OP_FALSE:            "OP_FALSE",
+	OP_POP:              "OP_POP",
+	OP_GET_LOCAL:        "OP_GET_LOCAL",
+	OP_SET_LOCAL:        "OP_SET_LOCAL",
+	OP_GET_GLOBAL:       "OP_GET_GLOBAL",
+	OP_DEFINE_GLOBAL:    "OP_DEFINE_GLOBAL",
+	OP_SET_GLOBAL:       "OP_SET_GLOBAL",
+	OP_GET_UPVALUE:      "OP_GET_UPVALUE",
+	OP_SET_UPVALUE:      "OP_SET_UPVALUE",
+	OP_GET_PROPERTY:     "OP_GET_PROPERTY",
+	OP_SET_PROPERTY:     "OP_SET_PROPERTY",
+	OP_GET_SUPER:        "OP_GET_SUPER",
+	OP_EQUAL:            "OP_EQUAL",
+	OP_NOT_EQUAL:        "OP_NOT_EQUAL",
+	OP_GREATER:          "OP_GREATER",
+	OP_GREATER_EQUAL:    "OP_GREATER_EQUAL",
+	OP_LESS:             "OP_LESS",
+	OP_LESS_EQUAL:       "OP_LESS_EQUAL",
+	OP_ADD:              "OP_ADD",
+	OP_SUBTRACT:         "OP_SUBTRACT",
+	OP_MULTIPLY:         "OP_MULTIPLY",
+	OP_DIVIDE:           "OP_DIVIDE",
+	OP_NOT:              "OP_NOT",
+	OP_NEGATE:           "OP_NEGATE",
+	OP_PRINT:            "OP_PRINT",
+	OP_JUMP:             "OP_JUMP",
+	OP_JUMP_IF_FALSE:    "OP_JUMP_IF_FALSE",
+	OP_LOOP:             "OP_LOOP",
+	OP_CALL:             "OP_CALL",
+	OP_CLOSURE:          "OP_CLOSURE",
+	OP_CLOSE_UPVALUE:    "OP_CLOSE_UPVALUE",
+	OP_RETURN:           "OP_RETURN",
+	OP_CLASS:            "OP_CLASS",
+	OP_CHECK_SUPERCLASS: "OP_CHECK_SUPERCLASS",
+	OP_INHERIT:          "OP_INHERIT",
+	OP_METHOD:           "OP_METHOD",
+	OP_LIST:             "OP_LIST",
+	OP_MAP:              "OP_MAP",
+	OP_INDEX:            "OP_INDEX",
+	OP_SET_INDEX:        "OP_SET_INDEX",
+	OP_SLICE:            "OP_SLICE",
+	OP_ITER:             "OP_ITER",
+	OP_FOR_ITER:         "OP_FOR_ITER",
+	OP_THROW:            "OP_THROW",
+	OP_TRY:              "OP_TRY",
+	OP_END_TRY:          "OP_END_TRY",
+	OP_RETHROW:          "OP_RETHROW",
+	OP_UNWIND:           "OP_UNWIND",
+}
+
+// String implements Stringer
+func (op OpCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("OP_UNKNOWN(%d)", byte(op))
+}
+
+// Kinds of exception handler pushed by OP_TRY
+const (
+	// The handler is a catch clause, and receives the thrown value
+	handlerCatch byte = iota
+	// The handler runs a finally block, and receives the
+	// exception to rethrow afterwards
+	handlerFinally
+)
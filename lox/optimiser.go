@@ -0,0 +1,398 @@
+package lox
+
+// Optimiser rewrites a program so that it does less work at runtime,
+// without changing what it does. Constant expressions are folded,
+// branches that can never run are dropped and groupings are removed.
+//
+// Operations are only folded when they would succeed, so that the
+// runtime errors of e.g. -"str" are kept.
+type Optimiser struct {
+	// The rewritten node from the last visit. A nil stmt means
+	// the statement does nothing and can be dropped.
+	expr Expr
+	stmt Stmt
+}
+
+func NewOptimiser() *Optimiser {
+	return &Optimiser{}
+}
+
+// Optimise rewrites the statements in place, and returns the
+// statements to run in their place
+func (o *Optimiser) Optimise(statements []Stmt) []Stmt {
+	var last Stmt
+	if len(statements) > 0 {
+		last = statements[len(statements)-1]
+	}
+	optimised := o.statements(statements)
+
+	// The value of a program is that of its final statement if
+	// it is an expression, so don't expose an earlier one
+	_, wasExpr := last.(*ExpressionStmt)
+	if len(optimised) > 0 && !wasExpr {
+		if _, isExpr := optimised[len(optimised)-1].(*ExpressionStmt); isExpr {
+			optimised = append(optimised, &BlockStmt{brace: stmtToken(last)})
+		}
+	}
+	return optimised
+}
+
+func (o *Optimiser) optimiseExpr(expr Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+	expr.Accept(o)
+	return o.expr
+}
+
+func (o *Optimiser) optimiseStmt(stmt Stmt) Stmt {
+	stmt.Accept(o)
+	return o.stmt
+}
+
+// statements optimises each statement, dropping those that do nothing
+func (o *Optimiser) statements(statements []Stmt) []Stmt {
+	optimised := statements[:0]
+	for _, stmt := range statements {
+		if stmt = o.optimiseStmt(stmt); stmt != nil {
+			optimised = append(optimised, stmt)
+		}
+	}
+	return optimised
+}
+
+// branch optimises the body of an if or loop statement, which
+// can't be left empty
+func (o *Optimiser) branch(stmt Stmt) Stmt {
+	if stmt == nil {
+		return nil
+	}
+	token := stmtToken(stmt)
+	if stmt = o.optimiseStmt(stmt); stmt == nil {
+		return &BlockStmt{brace: token}
+	}
+	return stmt
+}
+
+func (o *Optimiser) function(decl *FunctionStmt) {
+	decl.body = o.statements(decl.body)
+}
+
+// visitExpressionStmt implements StmtVisitor.
+func (o *Optimiser) visitExpressionStmt(stmt *ExpressionStmt) {
+	stmt.Expr = o.optimiseExpr(stmt.Expr)
+	o.stmt = stmt
+}
+
+// visitPrintStmt implements StmtVisitor.
+func (o *Optimiser) visitPrintStmt(stmt *PrintStmt) {
+	stmt.Expr = o.optimiseExpr(stmt.Expr)
+	o.stmt = stmt
+}
+
+// visitVarStmt implements StmtVisitor.
+func (o *Optimiser) visitVarStmt(stmt *VarStmt) {
+	stmt.Initialiser = o.optimiseExpr(stmt.Initialiser)
+	o.stmt = stmt
+}
+
+// visitBlockStmt implements StmtVisitor.
+func (o *Optimiser) visitBlockStmt(stmt *BlockStmt) {
+	stmt.statements = o.statements(stmt.statements)
+	if len(stmt.statements) == 0 {
+		o.stmt = nil
+		return
+	}
+	o.stmt = stmt
+}
+
+// visitIfStmt implements StmtVisitor.
+func (o *Optimiser) visitIfStmt(stmt *IfStmt) {
+	stmt.expr = o.optimiseExpr(stmt.expr)
+	literal, ok := stmt.expr.(*Literal)
+	if !ok {
+		stmt.thenBranch = o.branch(stmt.thenBranch)
+		stmt.elseBranch = o.branch(stmt.elseBranch)
+		o.stmt = stmt
+		return
+	}
+
+	taken := stmt.elseBranch
+	if isTruthy(literal.Value) {
+		taken = stmt.thenBranch
+	}
+	if taken == nil {
+		o.stmt = nil
+		return
+	}
+	o.stmt = o.optimiseStmt(taken)
+	// An if statement never produces a value, while an
+	// expression statement in its place would
+	if expr, ok := o.stmt.(*ExpressionStmt); ok {
+		o.stmt = &BlockStmt{brace: stmtToken(expr), statements: []Stmt{expr}}
+	}
+}
+
+// visitWhileStmt implements StmtVisitor.
+func (o *Optimiser) visitWhileStmt(stmt *WhileStmt) {
+	stmt.expr = o.optimiseExpr(stmt.expr)
+	if literal, ok := stmt.expr.(*Literal); ok && !isTruthy(literal.Value) {
+		o.stmt = nil
+		return
+	}
+	stmt.body = o.branch(stmt.body)
+	stmt.increment = o.optimiseExpr(stmt.increment)
+	o.stmt = stmt
+}
+
+// visitForInStmt implements StmtVisitor.
+func (o *Optimiser) visitForInStmt(stmt *ForInStmt) {
+	stmt.iterable = o.optimiseExpr(stmt.iterable)
+	stmt.body = o.branch(stmt.body)
+	o.stmt = stmt
+}
+
+// visitFunctionStmt implements StmtVisitor.
+func (o *Optimiser) visitFunctionStmt(stmt *FunctionStmt) {
+	o.function(stmt)
+	o.stmt = stmt
+}
+
+// visitReturnStmt implements StmtVisitor.
+func (o *Optimiser) visitReturnStmt(stmt *ReturnStmt) {
+	stmt.value = o.optimiseExpr(stmt.value)
+	o.stmt = stmt
+}
+
+// visitClassStmt implements StmtVisitor.
+func (o *Optimiser) visitClassStmt(stmt *ClassStmt) {
+	for _, method := range stmt.methods {
+		o.function(method)
+	}
+	o.stmt = stmt
+}
+
+// visitBreakStmt implements StmtVisitor.
+func (o *Optimiser) visitBreakStmt(stmt *BreakStmt) {
+	o.stmt = stmt
+}
+
+// visitContinueStmt implements StmtVisitor.
+func (o *Optimiser) visitContinueStmt(stmt *ContinueStmt) {
+	o.stmt = stmt
+}
+
+// visitThrowStmt implements StmtVisitor.
+func (o *Optimiser) visitThrowStmt(stmt *ThrowStmt) {
+	stmt.value = o.optimiseExpr(stmt.value)
+	o.stmt = stmt
+}
+
+// visitTryStmt implements StmtVisitor.
+func (o *Optimiser) visitTryStmt(stmt *TryStmt) {
+	stmt.body = o.statements(stmt.body)
+	// Empty clauses are kept, as nil means there isn't one
+	if stmt.catchBody != nil {
+		stmt.catchBody = append([]Stmt{}, o.statements(stmt.catchBody)...)
+	}
+	if stmt.finallyBody != nil {
+		stmt.finallyBody = append([]Stmt{}, o.statements(stmt.finallyBody)...)
+	}
+	o.stmt = stmt
+}
+
+// visitBinaryExpr implements ExprVisitor.
+func (o *Optimiser) visitBinaryExpr(expr *Binary) {
+	expr.Left = o.optimiseExpr(expr.Left)
+	expr.Right = o.optimiseExpr(expr.Right)
+	o.expr = expr
+
+	left, okLeft := expr.Left.(*Literal)
+	right, okRight := expr.Right.(*Literal)
+	if !(okLeft && okRight) {
+		return
+	}
+	if value, ok := foldBinary(expr.Op.Type, left.Value, right.Value); ok {
+		o.expr = &Literal{Value: value, token: expr.Op}
+	}
+}
+
+// foldBinary evaluates a binary operator on constants, reporting
+// false if that would be a runtime error
+func foldBinary(op TokenType, left any, right any) (any, bool) {
+	switch op {
+	case EQUAL_EQUAL:
+		return isEqual(left, right), true
+	case BANG_EQUAL:
+		return !isEqual(left, right), true
+	}
+
+	if l, ok := left.(string); ok && op == PLUS {
+		if r, ok := right.(string); ok {
+			return l + r, true
+		}
+		return nil, false
+	}
+	l, okLeft := left.(float64)
+	r, okRight := right.(float64)
+	if !(okLeft && okRight) {
+		return nil, false
+	}
+	switch op {
+	case GREATER:
+		return l > r, true
+	case GREATER_EQUAL:
+		return l >= r, true
+	case LESS:
+		return l < r, true
+	case LESS_EQUAL:
+		return l <= r, true
+	case MINUS:
+		return l - r, true
+	case PLUS:
+		return l + r, true
+	case SLASH:
+		return l / r, true
+	case STAR:
+		return l * r, true
+	}
+	return nil, false
+}
+
+// visitLogicalExpr implements ExprVisitor.
+func (o *Optimiser) visitLogicalExpr(expr *Logical) {
+	expr.left = o.optimiseExpr(expr.left)
+	expr.right = o.optimiseExpr(expr.right)
+	o.expr = expr
+
+	left, ok := expr.left.(*Literal)
+	if !ok {
+		return
+	}
+	// The left operand is the result if it decides the
+	// outcome, and otherwise the right one is
+	if isTruthy(left.Value) == (expr.op.Type == OR) {
+		o.expr = left
+	} else {
+		o.expr = expr.right
+	}
+}
+
+// visitUnaryExpr implements ExprVisitor.
+func (o *Optimiser) visitUnaryExpr(expr *Unary) {
+	expr.Right = o.optimiseExpr(expr.Right)
+	o.expr = expr
+
+	operand, ok := expr.Right.(*Literal)
+	if !ok {
+		return
+	}
+	switch expr.Op.Type {
+	case BANG:
+		o.expr = &Literal{Value: !isTruthy(operand.Value), token: expr.Op}
+	case MINUS:
+		if value, ok := operand.Value.(float64); ok {
+			o.expr = &Literal{Value: -value, token: expr.Op}
+		}
+	}
+}
+
+// visitGroupingExpr implements ExprVisitor.
+func (o *Optimiser) visitGroupingExpr(expr *Grouping) {
+	// Groupings only matter to the parser
+	o.expr = o.optimiseExpr(expr.Expr)
+}
+
+// visitLiteralExpr implements ExprVisitor.
+func (o *Optimiser) visitLiteralExpr(expr *Literal) {
+	o.expr = expr
+}
+
+// visitVariableExpr implements ExprVisitor.
+func (o *Optimiser) visitVariableExpr(expr *Variable) {
+	o.expr = expr
+}
+
+// visitAssignExpr implements ExprVisitor.
+func (o *Optimiser) visitAssignExpr(expr *Assign) {
+	expr.Value = o.optimiseExpr(expr.Value)
+	o.expr = expr
+}
+
+// visitCallExpr implements ExprVisitor.
+func (o *Optimiser) visitCallExpr(expr *Call) {
+	expr.callee = o.optimiseExpr(expr.callee)
+	for idx, arg := range expr.arguments {
+		expr.arguments[idx] = o.optimiseExpr(arg)
+	}
+	o.expr = expr
+}
+
+// visitGetExpr implements ExprVisitor.
+func (o *Optimiser) visitGetExpr(expr *Get) {
+	expr.object = o.optimiseExpr(expr.object)
+	o.expr = expr
+}
+
+// visitSetExpr implements ExprVisitor.
+func (o *Optimiser) visitSetExpr(expr *Set) {
+	expr.object = o.optimiseExpr(expr.object)
+	expr.value = o.optimiseExpr(expr.value)
+	o.expr = expr
+}
+
+// visitThisExpr implements ExprVisitor.
+func (o *Optimiser) visitThisExpr(expr *This) {
+	o.expr = expr
+}
+
+// visitSuperExpr implements ExprVisitor.
+func (o *Optimiser) visitSuperExpr(expr *Super) {
+	o.expr = expr
+}
+
+// visitListLiteralExpr implements ExprVisitor.
+func (o *Optimiser) visitListLiteralExpr(expr *ListLiteral) {
+	for idx, element := range expr.elements {
+		expr.elements[idx] = o.optimiseExpr(element)
+	}
+	o.expr = expr
+}
+
+// visitMapLiteralExpr implements ExprVisitor.
+func (o *Optimiser) visitMapLiteralExpr(expr *MapLiteral) {
+	for idx := range expr.keys {
+		expr.keys[idx] = o.optimiseExpr(expr.keys[idx])
+		expr.values[idx] = o.optimiseExpr(expr.values[idx])
+	}
+	o.expr = expr
+}
+
+// visitIndexExpr implements ExprVisitor.
+func (o *Optimiser) visitIndexExpr(expr *Index) {
+	expr.object = o.optimiseExpr(expr.object)
+	expr.index = o.optimiseExpr(expr.index)
+	o.expr = expr
+}
+
+// visitIndexSetExpr implements ExprVisitor.
+func (o *Optimiser) visitIndexSetExpr(expr *IndexSet) {
+	expr.object = o.optimiseExpr(expr.object)
+	expr.index = o.optimiseExpr(expr.index)
+	expr.value = o.optimiseExpr(expr.value)
+	o.expr = expr
+}
+
+// visitSliceExpr implements ExprVisitor.
+func (o *Optimiser) visitSliceExpr(expr *Slice) {
+	expr.object = o.optimiseExpr(expr.object)
+	expr.start = o.optimiseExpr(expr.start)
+	expr.end = o.optimiseExpr(expr.end)
+	o.expr = expr
+}
+
+// visitLambdaExpr implements ExprVisitor.
+func (o *Optimiser) visitLambdaExpr(expr *Lambda) {
+	o.function(expr.decl)
+	o.expr = expr
+}
@@ -0,0 +1,79 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// osModule gives scripts access to the environment of the
+// process, and lets them run other programs
+func osModule(h *host) *LoxModule {
+	m := newModule("os")
+
+	// Returns nil if the variable isn't set
+	m.native("getenv", 1, func(args []any) (any, error) {
+		if err := h.caps.require("os.getenv", CapEnv); err != nil {
+			return nil, err
+		}
+		name, err := stringArg("os.getenv", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value, nil
+		}
+		return nil, nil
+	})
+
+	// Runs a program with the remaining arguments, returning a map
+	// of its exit status and output. Failing with a non-zero status
+	// isn't an error, but failing to start is.
+	m.native("exec", Variadic, func(args []any) (any, error) {
+		if err := h.caps.require("os.exec", CapExec); err != nil {
+			return nil, err
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Expected at least 1 argument but received 0")
+		}
+		argv := make([]string, len(args))
+		for idx := range args {
+			arg, err := stringArg("os.exec", args, idx)
+			if err != nil {
+				return nil, err
+			}
+			argv[idx] = arg
+		}
+
+		// The program is killed if the run is cancelled
+		ctx := h.budget.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		if ctx.Err() != nil {
+			return nil, CancelledError{Err: ctx.Err()}
+		}
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		// The map is counted once it's returned, but not its output
+		if err := h.budget.allocate(stdout.Len() + stderr.Len()); err != nil {
+			return nil, err
+		}
+		result := NewLoxMap()
+		result.Set("status", float64(cmd.ProcessState.ExitCode()))
+		result.Set("stdout", stdout.String())
+		result.Set("stderr", stderr.String())
+		return result, nil
+	})
+
+	return m
+}
@@ -3,6 +3,7 @@ package lox
 type Parser struct {
 	tokens []Token
 	current int
+	errors Diagnostics
 }
 
 func NewParser(tokens []Token) *Parser {
@@ -16,29 +17,42 @@ func (e parserError) Error() string {
 	return "Encountered error during parsing"
 }
 
-func (p *Parser) Parse() (statements []Stmt, err error) {
+// Parse parses every declaration in the token stream. After a syntax
+// error the parser synchronises to the next statement and carries on,
+// so that all errors are returned together as Diagnostics.
+func (p *Parser) Parse() ([]Stmt, error) {
+	// Default cap necessary?
+	statements := make([]Stmt, 0, 100)
+	for !p.isAtEnd() {
+		if stmt := p.declaration(); stmt != nil {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements, p.errors.errorOrNil()
+}
+
+// declaration is where the parser recovers from a parserError, so
+// it returns nil for a declaration that couldn't be parsed
+func (p *Parser) declaration() (stmt Stmt) {
 	defer func() {
 		if r := recover(); r != nil {
 			// Determine that we are recovering from a 
 			// parserError
 			if _, ok := r.(parserError); ok {
 				p.synchronise()
+				stmt = nil
 			} else {
 				panic(r)
 			}
 		}
 
 	}()
-	// Default cap necessary?
-	statements = make([]Stmt, 0, 100)
-	for !p.isAtEnd() {
-		statements = append(statements, p.declaration())
+	if p.match(CLASS) {
+		return p.classDecl()
 	}
-	return statements, nil
-}
-
-func (p *Parser) declaration() Stmt {
-	if p.match(FUN) {
+	// Anonymous functions are parsed as expression statements
+	if p.check(FUN) && p.checkNext(IDENTIFIER) {
+		p.advance()
 		function := p.function("function")
 		return function
 	}
@@ -48,23 +62,100 @@ func (p *Parser) declaration() Stmt {
 	return p.statement()
 }
 
+func (p *Parser) classDecl() Stmt {
+	name := p.consume(IDENTIFIER, "Expect class name")
+
+	var superclass *Variable
+	if p.match(LESS) {
+		superName := p.consume(IDENTIFIER, "Expect superclass name")
+		if superName.Lexeme == name.Lexeme {
+			// Not a parserError, as the parser is not confused
+			p.error(superName, "A class can't inherit from itself")
+		}
+		superclass = &Variable{Name: superName}
+	}
+
+	p.consume(LEFT_BRACE, "Expect '{' before class body")
+
+	var methods []*FunctionStmt
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		methods = append(methods, p.function("method"))
+	}
+	p.consume(RIGHT_BRACE, "Expect '}' after class body")
+
+	return &ClassStmt{name: name, superclass: superclass, methods: methods}
+}
+
 func (p *Parser) function(kind string) *FunctionStmt{
 	name := p.consume(IDENTIFIER, "Expect " + kind + " name")
 	p.consume(LEFT_PAREN, "Expect '(' after " + kind + " name")
+	params := p.parameters()
+	p.consume(LEFT_BRACE, "Expect '{' before " + kind + " body")
+	body := p.block()
+	return &FunctionStmt{name: name, params: params, body: body}
+}
+
+// parameters parses a parameter list after the opening parenthesis,
+// up to and including the closing one
+func (p *Parser) parameters() []Token {
 	var params []Token
 	if !p.check(RIGHT_PAREN) {
 		params = append(params, p.consume(IDENTIFIER, "Expect parameter name"))
 		for p.match(COMMA) {
 			if len(params) >= 255 {
-				ErrorOnToken(p.peek(), "Can't have more than 255 parameters")
+				p.error(p.peek(), "Can't have more than 255 parameters")
 			}
 			params = append(params, p.consume(IDENTIFIER, "Expect parameter name"))
 		}
 	}
 	p.consume(RIGHT_PAREN, "Expect ')' after parameters")
-	p.consume(LEFT_BRACE, "Expect '{' before " + kind + " body")
+	return params
+}
+
+// lambda parses 'fun (params) { body }' after the 'fun' keyword
+func (p *Parser) lambda() Expr {
+	keyword := p.previous()
+	p.consume(LEFT_PAREN, "Expect '(' after 'fun'")
+	params := p.parameters()
+	p.consume(LEFT_BRACE, "Expect '{' before function body")
 	body := p.block()
-	return &FunctionStmt{name: name, params: params, body: body}
+	return &Lambda{keyword: keyword, decl: &FunctionStmt{params: params, body: body}}
+}
+
+// isArrowFunction looks ahead from an opening parenthesis to see
+// whether it starts the parameters of an arrow function, rather
+// than a grouping
+func (p *Parser) isArrowFunction() bool {
+	offset := 1
+	if !p.checkAt(offset, RIGHT_PAREN) {
+		for {
+			if !p.checkAt(offset, IDENTIFIER) {
+				return false
+			}
+			offset++
+			if !p.checkAt(offset, COMMA) {
+				break
+			}
+			offset++
+		}
+	}
+	return p.checkAt(offset, RIGHT_PAREN) && p.checkAt(offset+1, ARROW)
+}
+
+// arrowFunction parses '(params) => expr' or '(params) => { body }'
+func (p *Parser) arrowFunction() Expr {
+	p.consume(LEFT_PAREN, "Expect '(' before parameters")
+	params := p.parameters()
+	arrow := p.consume(ARROW, "Expect '=>' after parameters")
+
+	var body []Stmt
+	if p.match(LEFT_BRACE) {
+		body = p.block()
+	} else {
+		// An expression body is returned implicitly
+		body = []Stmt{&ReturnStmt{keyword: arrow, value: p.expression()}}
+	}
+	return &Lambda{keyword: arrow, decl: &FunctionStmt{params: params, body: body}}
 }
 
 func (p *Parser) variableDecl() *VarStmt {
@@ -95,8 +186,28 @@ func (p *Parser) statement() Stmt {
 	if p.match(RETURN) {
 		return p.returnStatement()
 	}
+	if p.match(THROW) {
+		keyword := p.previous()
+		value := p.expression()
+		p.consume(SEMICOLON, "Expect ';' after thrown value")
+		return &ThrowStmt{keyword: keyword, value: value}
+	}
+	if p.match(TRY) {
+		return p.tryStatement()
+	}
+	if p.match(BREAK) {
+		keyword := p.previous()
+		p.consume(SEMICOLON, "Expect ';' after 'break'")
+		return &BreakStmt{keyword: keyword}
+	}
+	if p.match(CONTINUE) {
+		keyword := p.previous()
+		p.consume(SEMICOLON, "Expect ';' after 'continue'")
+		return &ContinueStmt{keyword: keyword}
+	}
 	if p.match(LEFT_BRACE) {
-		return &BlockStmt{statements: p.block()}
+		brace := p.previous()
+		return &BlockStmt{brace: brace, statements: p.block()}
 	}
 
 	// Fallthrough case, as difficult to detect based on token
@@ -130,7 +241,20 @@ func (p *Parser) whileStatement() Stmt {
 }
 
 func (p *Parser) forStatement() Stmt {
+	keyword := p.previous()
 	p.consume(LEFT_PAREN, "Expect '(' following 'for'")
+
+	// Look ahead for 'var name in'
+	if p.check(VAR) && p.checkNext(IDENTIFIER) && p.checkAt(2, IN) {
+		p.advance()
+		name := p.advance()
+		p.advance()
+		iterable := p.expression()
+		p.consume(RIGHT_PAREN, "Expect ')' after for-in clause")
+		body := p.statement()
+		return &ForInStmt{keyword: keyword, name: name, iterable: iterable, body: body}
+	}
+
 	var init Stmt
 	var cond Expr
 	var inc Expr
@@ -152,20 +276,42 @@ func (p *Parser) forStatement() Stmt {
 
 	// Consume the for block statement
 	body := p.statement()
-	if inc != nil {
-		body = &BlockStmt{statements: []Stmt{body, &ExpressionStmt{Expr: inc}}}
-	}
 	if cond == nil {
-		cond = &Literal{Value: true}
+		cond = &Literal{Value: true, token: keyword}
 	}
-	body = &WhileStmt{expr: cond, body: body}
+	// The increment is kept separate from the body so that
+	// it still runs after a 'continue'
+	body = &WhileStmt{expr: cond, body: body, increment: inc}
 	if init != nil {
-		body = &BlockStmt{statements: []Stmt{init, body}}
+		body = &BlockStmt{brace: keyword, statements: []Stmt{init, body}}
 	}
 
 	return body
 }
 
+func (p *Parser) tryStatement() Stmt {
+	stmt := &TryStmt{keyword: p.previous()}
+	p.consume(LEFT_BRACE, "Expect '{' after 'try'")
+	stmt.body = p.block()
+
+	if p.match(CATCH) {
+		p.consume(LEFT_PAREN, "Expect '(' after 'catch'")
+		stmt.catchName = p.consume(IDENTIFIER, "Expect name for caught value")
+		p.consume(RIGHT_PAREN, "Expect ')' after caught value name")
+		p.consume(LEFT_BRACE, "Expect '{' after catch clause")
+		// Make sure an empty catch block is non-nil
+		stmt.catchBody = append([]Stmt{}, p.block()...)
+	}
+	if p.match(FINALLY) {
+		p.consume(LEFT_BRACE, "Expect '{' after 'finally'")
+		stmt.finallyBody = append([]Stmt{}, p.block()...)
+	}
+	if stmt.catchBody == nil && stmt.finallyBody == nil {
+		panic(p.parserError(p.peek(), "Expect 'catch' or 'finally' after try block"))
+	}
+	return stmt
+}
+
 func (p *Parser) returnStatement() Stmt {
 	keyword := p.previous()
 	var value Expr
@@ -179,7 +325,9 @@ func (p *Parser) returnStatement() Stmt {
 func (p *Parser) block() []Stmt {
 	var statements []Stmt
 	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
-		statements = append(statements, p.declaration())
+		if stmt := p.declaration(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
 	p.consume(RIGHT_BRACE, "Expect '}' after block")
 	return statements
@@ -203,7 +351,13 @@ func (p *Parser) assignment() Expr {
 		if v, ok := expr.(*Variable); ok {
 			return &Assign{Name: v.Name, Value: value}
 		}
-		ErrorOnToken(equals, "Invalid assignment target")
+		if g, ok := expr.(*Get); ok {
+			return &Set{object: g.object, name: g.name, value: value}
+		}
+		if idx, ok := expr.(*Index); ok {
+			return &IndexSet{object: idx.object, bracket: idx.bracket, index: idx.index, value: value}
+		}
+		p.error(equals, "Invalid assignment target")
 	}
 	return expr
 }
@@ -213,7 +367,7 @@ func (p *Parser) logicalOr() Expr {
 	for p.match(OR) {
 		op := p.previous()
 		right := p.logicalAnd()
-		return &Logical{op:op, left: expr, right: right}
+		expr = &Logical{op:op, left: expr, right: right}
 	}
 	return expr
 }
@@ -223,7 +377,7 @@ func (p *Parser) logicalAnd() Expr {
 	for p.match(AND) {
 		op := p.previous()
 		right := p.equality()
-		return &Logical{op:op, left: expr, right: right}
+		expr = &Logical{op:op, left: expr, right: right}
 	}
 	return expr
 }
@@ -281,7 +435,12 @@ func (p *Parser) call() Expr {
 	expr := p.primary()
 	for {
 		if p.match(LEFT_PAREN) {
-			return p.finishCall(expr)
+			expr = p.finishCall(expr)
+		} else if p.match(DOT) {
+			name := p.consume(IDENTIFIER, "Expect property name after '.'")
+			expr = &Get{object: expr, name: name}
+		} else if p.match(LEFT_BRACKET) {
+			expr = p.finishIndex(expr)
 		} else {
 			break
 		}
@@ -296,7 +455,7 @@ func (p *Parser) finishCall(callee Expr) Expr {
 		args = append(args, p.expression())
 		for p.match(COMMA) {
 			if len(args) >= 255 {
-				ErrorOnToken(p.peek(), "Can't have more than 255 arguments")
+				p.error(p.peek(), "Can't have more than 255 arguments")
 			}
 			args = append(args, p.expression())
 		}
@@ -305,16 +464,88 @@ func (p *Parser) finishCall(callee Expr) Expr {
 	return &Call{paren: paren, callee: callee, arguments: args}
 }
 
+// finishIndex parses either an index or a slice,
+// after the opening bracket
+func (p *Parser) finishIndex(object Expr) Expr {
+	bracket := p.previous()
+	var start Expr
+	if !p.check(COLON) {
+		start = p.expression()
+	}
+	if p.match(COLON) {
+		var end Expr
+		if !p.check(RIGHT_BRACKET) {
+			end = p.expression()
+		}
+		p.consume(RIGHT_BRACKET, "Expect ']' after slice")
+		return &Slice{object: object, bracket: bracket, start: start, end: end}
+	}
+	p.consume(RIGHT_BRACKET, "Expect ']' after index")
+	return &Index{object: object, bracket: bracket, index: start}
+}
+
+func (p *Parser) listLiteral() Expr {
+	bracket := p.previous()
+	var elements []Expr
+	for !p.check(RIGHT_BRACKET) && !p.isAtEnd() {
+		elements = append(elements, p.expression())
+		// Allow a trailing comma
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	p.consume(RIGHT_BRACKET, "Expect ']' after list elements")
+	return &ListLiteral{bracket: bracket, elements: elements}
+}
+
+// mapLiteral parses the entries of a map, after the opening brace.
+// A brace is only parsed as a map in expression position; at the
+// start of a statement it always begins a block.
+func (p *Parser) mapLiteral() Expr {
+	brace := p.previous()
+	var keys, values []Expr
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		keys = append(keys, p.expression())
+		p.consume(COLON, "Expect ':' after map key")
+		values = append(values, p.expression())
+		// Allow a trailing comma
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	p.consume(RIGHT_BRACE, "Expect '}' after map entries")
+	return &MapLiteral{brace: brace, keys: keys, values: values}
+}
+
 func (p *Parser) primary() Expr {
-	if p.match(TRUE) { return &Literal{Value: true} }
-	if p.match(FALSE) { return &Literal{Value: false} }
-	if p.match(NIL) { return &Literal{Value: nil} }
+	if p.match(TRUE) { return &Literal{Value: true, token: p.previous()} }
+	if p.match(FALSE) { return &Literal{Value: false, token: p.previous()} }
+	if p.match(NIL) { return &Literal{Value: nil, token: p.previous()} }
 	if p.match(NUMBER, STRING) {
-		return &Literal{Value: p.previous().Literal}
+		return &Literal{Value: p.previous().Literal, token: p.previous()}
+	}
+	if p.match(THIS) { return &This{keyword: p.previous()} }
+	if p.match(SUPER) {
+		keyword := p.previous()
+		p.consume(DOT, "Expect '.' after 'super'")
+		method := p.consume(IDENTIFIER, "Expect superclass method name")
+		return &Super{keyword: keyword, method: method}
 	}
 	if p.match(IDENTIFIER) {
 		return &Variable{Name: p.previous()}
 	}
+	if p.match(LEFT_BRACKET) {
+		return p.listLiteral()
+	}
+	if p.match(LEFT_BRACE) {
+		return p.mapLiteral()
+	}
+	if p.match(FUN) {
+		return p.lambda()
+	}
+	if p.check(LEFT_PAREN) && p.isArrowFunction() {
+		return p.arrowFunction()
+	}
 	if p.match(LEFT_PAREN) {
 		expr := p.expression()
 		p.consume(RIGHT_PAREN, "Expect ')' after expression.")
@@ -344,6 +575,16 @@ func (p *Parser) check(ttype TokenType) bool {
 	return p.peek().Type == ttype
 }
 
+func (p *Parser) checkNext(ttype TokenType) bool {
+	return p.checkAt(1, ttype)
+}
+
+// checkAt checks the type of the token offset places ahead
+func (p *Parser) checkAt(offset int, ttype TokenType) bool {
+	if p.current + offset >= len(p.tokens) { return false }
+	return p.tokens[p.current + offset].Type == ttype
+}
+
 func (p *Parser) advance() Token {
 	if !p.isAtEnd() { p.current += 1 }
 	return p.previous()
@@ -371,7 +612,7 @@ func (p *Parser) synchronise() {
 		}
 
 		switch p.peek().Type {
-		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN:
+		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN, THROW, TRY:
 			return
 		}
 
@@ -379,7 +620,13 @@ func (p *Parser) synchronise() {
 	}
 }
 
+// error records an error without unwinding, for when the
+// parser isn't confused about where it is
+func (p *Parser) error(token Token, msg string) {
+	p.errors = append(p.errors, ErrorOnToken(CodeSyntax, token, msg))
+}
+
 func(p *Parser) parserError(token Token, msg string) error {
-	ErrorOnToken(token, msg)
+	p.error(token, msg)
 	return parserError{}
 }
@@ -0,0 +1,54 @@
+package lox
+
+// prelude is Lox source that is run in every new interpreter,
+// for builtins which are simplest to write in Lox itself
+const prelude = `
+class Error {
+	init(message) {
+		this.message = message;
+		this.line = nil;
+	}
+}
+`
+
+// parsePrelude parses the prelude, panicking if it is broken
+// as that is a bug in glox rather than in a script
+func parsePrelude() []Stmt {
+	tokens, err := NewScanner("<prelude>", prelude).ScanTokens()
+	if err != nil {
+		panic(err)
+	}
+	statements, err := NewParser(tokens).Parse()
+	if err != nil {
+		panic(err)
+	}
+	return statements
+}
+
+// loadPrelude runs the prelude in the interpreter
+func (i *Interpreter) loadPrelude() {
+	statements := parsePrelude()
+	if err := NewResolver(i).Resolve(statements); err != nil {
+		panic(err)
+	}
+	if _, err := i.Interpret(statements); err != nil {
+		panic(err)
+	}
+	i.errorClass = i.globals.values["Error"].(*LoxClass)
+}
+
+// loadPrelude compiles and runs the prelude in the machine
+func (m *Machine) loadPrelude() {
+	statements := parsePrelude()
+	if err := NewResolver(nil).Resolve(statements); err != nil {
+		panic(err)
+	}
+	function, err := NewCompiler().Compile(statements)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := m.Interpret(function); err != nil {
+		panic(err)
+	}
+	m.errorClass = m.globals["Error"].(*machineClass)
+}
@@ -0,0 +1,76 @@
+package lox
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Program is a script compiled to bytecode, ready to be
+// inspected or run by a VM with the Bytecode backend
+type Program struct {
+	function *funcProto
+}
+
+// Compile scans, parses and compiles source without running it. As
+// with EvalNamed, name identifies the source in diagnostics. Options
+// which don't affect compilation, such as WithBackend, are ignored.
+func Compile(name string, source string, opts ...Option) (*Program, error) {
+	statements, err := prepare(name, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	function, err := compile(statements)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{function: function}, nil
+}
+
+// FormatAST parses source and returns its syntax tree as
+// S-expressions, after the rewrites of any WithOptimiser option
+func FormatAST(name string, source string, opts ...Option) (string, error) {
+	statements, err := prepare(name, source, opts)
+	if err != nil {
+		return "", err
+	}
+	return (&ASTPrinter{}).PrintProgram(statements), nil
+}
+
+// prepare parses source, and optimises it if opts ask for that
+func prepare(name string, source string, opts []Option) ([]Stmt, error) {
+	statements, err := parse(name, source)
+	if err != nil {
+		return nil, err
+	}
+	config := &VM{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.optimise {
+		return optimise(statements)
+	}
+	return statements, nil
+}
+
+// Disassemble writes a listing of the program's bytecode to w,
+// starting with the top-level code and followed by each function
+func (p *Program) Disassemble(w io.Writer) {
+	disassembleFunction(w, p.function, topLevelName)
+}
+
+// Run runs a compiled program in the VM, which must use the Bytecode
+// backend. Errors are returned in the same way as by EvalNamed.
+func (vm *VM) Run(p *Program) (Value, error) {
+	return vm.RunContext(context.Background(), p)
+}
+
+// RunContext is like Run, but stops the program with a
+// CancelledError if ctx is cancelled or passes its deadline
+func (vm *VM) RunContext(ctx context.Context, p *Program) (Value, error) {
+	if vm.machine == nil {
+		return nil, errors.New("compiled programs can only be run by the bytecode backend")
+	}
+	vm.machine.budget.start(ctx)
+	return diagnose(vm.machine.Interpret(p.function))
+}
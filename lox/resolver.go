@@ -1,58 +1,188 @@
 package lox
 
+type FunctionType int
+
+const (
+	NONE FunctionType = iota
+	FUNCTION
+	INITIALISER
+	METHOD
+)
+
+type ClassType int
+
+const (
+	NO_CLASS ClassType = iota
+	IN_CLASS
+	IN_SUBCLASS
+)
+
+// binding is a variable declared in a local scope
+type binding struct {
+	// Index of the variable in its scope, in order of declaration
+	slot int
+	// Whether the variable's initialiser has finished
+	defined bool
+}
+
 type Resolver struct {
-	interpreter *Interpreter
-	scopes      *Stack[map[string]bool]
+	interpreter     *Interpreter
+	scopes          *Stack[map[string]*binding]
+	currentFunction FunctionType
+	currentClass    ClassType
+	// Number of loops enclosing the current statement
+	// within the current function
+	loopDepth int
+	errors    Diagnostics
 }
 
+// NewResolver creates a resolver which records the depths of local
+// variables with i. With a nil interpreter, the resolver only checks
+// for static errors, e.g. for the bytecode compiler.
 func NewResolver(i *Interpreter) *Resolver {
-	return &Resolver{interpreter: i, scopes: NewStack[map[string]bool]()}
+	return &Resolver{interpreter: i, scopes: NewStack[map[string]*binding](), currentFunction: NONE, currentClass: NO_CLASS}
+}
+
+// Resolve performs static resolution of variables, recording the
+// scope depth and slot of each local variable with the interpreter
+func (r *Resolver) Resolve(statements []Stmt) error {
+	r.resolve(statements)
+	return r.errors.errorOrNil()
+}
+
+func (r *Resolver) error(token Token, msg string, notes ...string) {
+	err := ErrorOnToken(CodeResolve, token, msg)
+	err.Notes = notes
+	r.errors = append(r.errors, err)
 }
 
 // visitAssignExpr implements ExprVisitor.
-func (*Resolver) visitAssignExpr(*Assign) {
-	panic("unimplemented")
+func (r *Resolver) visitAssignExpr(expr *Assign) {
+	r.resolveExpr(expr.Value)
+	r.resolveLocal(expr, expr.Name)
 }
 
 // visitBinaryExpr implements ExprVisitor.
-func (*Resolver) visitBinaryExpr(*Binary) {
-	panic("unimplemented")
+func (r *Resolver) visitBinaryExpr(expr *Binary) {
+	r.resolveExpr(expr.Left)
+	r.resolveExpr(expr.Right)
 }
 
 // visitCallExpr implements ExprVisitor.
-func (*Resolver) visitCallExpr(*Call) {
-	panic("unimplemented")
+func (r *Resolver) visitCallExpr(expr *Call) {
+	r.resolveExpr(expr.callee)
+	for _, arg := range expr.arguments {
+		r.resolveExpr(arg)
+	}
 }
 
 // visitGroupingExpr implements ExprVisitor.
-func (*Resolver) visitGroupingExpr(*Grouping) {
-	panic("unimplemented")
+func (r *Resolver) visitGroupingExpr(expr *Grouping) {
+	r.resolveExpr(expr.Expr)
 }
 
 // visitLiteralExpr implements ExprVisitor.
-func (*Resolver) visitLiteralExpr(*Literal) {
-	panic("unimplemented")
-}
+func (*Resolver) visitLiteralExpr(*Literal) {}
 
 // visitLogicalExpr implements ExprVisitor.
-func (*Resolver) visitLogicalExpr(*Logical) {
-	panic("unimplemented")
+func (r *Resolver) visitLogicalExpr(expr *Logical) {
+	r.resolveExpr(expr.left)
+	r.resolveExpr(expr.right)
 }
 
 // visitUnaryExpr implements ExprVisitor.
-func (*Resolver) visitUnaryExpr(*Unary) {
-	panic("unimplemented")
+func (r *Resolver) visitUnaryExpr(expr *Unary) {
+	r.resolveExpr(expr.Right)
 }
 
 // visitVariableExpr implements ExprVisitor.
 func (r *Resolver) visitVariableExpr(expr *Variable) {
 	scope, err := r.scopes.Peek()
-	if err == nil && !scope[expr.Name.Lexeme] {
-		ErrorOnToken(expr.Name, "Can't read local variable in its own initialiser")
+	if b, declared := scope[expr.Name.Lexeme]; err == nil && declared && !b.defined {
+		r.error(expr.Name, "Can't read local variable in its own initialiser")
 	}
 	r.resolveLocal(expr, expr.Name)
 }
 
+// visitGetExpr implements ExprVisitor.
+func (r *Resolver) visitGetExpr(expr *Get) {
+	// Properties are looked up dynamically, so only the
+	// object expression needs resolving
+	r.resolveExpr(expr.object)
+}
+
+// visitSetExpr implements ExprVisitor.
+func (r *Resolver) visitSetExpr(expr *Set) {
+	r.resolveExpr(expr.value)
+	r.resolveExpr(expr.object)
+}
+
+// visitThisExpr implements ExprVisitor.
+func (r *Resolver) visitThisExpr(expr *This) {
+	if r.currentClass == NO_CLASS {
+		r.error(expr.keyword, "Can't use 'this' outside of a class")
+		return
+	}
+	r.resolveLocal(expr, expr.keyword)
+}
+
+// visitSuperExpr implements ExprVisitor.
+func (r *Resolver) visitSuperExpr(expr *Super) {
+	if r.currentClass == NO_CLASS {
+		r.error(expr.keyword, "Can't use 'super' outside of a class")
+		return
+	} else if r.currentClass != IN_SUBCLASS {
+		r.error(expr.keyword, "Can't use 'super' in a class with no superclass",
+			"a superclass is declared with 'class Name < Superclass'")
+		return
+	}
+	r.resolveLocal(expr, expr.keyword)
+}
+
+// visitListLiteralExpr implements ExprVisitor.
+func (r *Resolver) visitListLiteralExpr(expr *ListLiteral) {
+	for _, element := range expr.elements {
+		r.resolveExpr(element)
+	}
+}
+
+// visitIndexExpr implements ExprVisitor.
+func (r *Resolver) visitIndexExpr(expr *Index) {
+	r.resolveExpr(expr.object)
+	r.resolveExpr(expr.index)
+}
+
+// visitIndexSetExpr implements ExprVisitor.
+func (r *Resolver) visitIndexSetExpr(expr *IndexSet) {
+	r.resolveExpr(expr.value)
+	r.resolveExpr(expr.object)
+	r.resolveExpr(expr.index)
+}
+
+// visitSliceExpr implements ExprVisitor.
+func (r *Resolver) visitSliceExpr(expr *Slice) {
+	r.resolveExpr(expr.object)
+	if expr.start != nil {
+		r.resolveExpr(expr.start)
+	}
+	if expr.end != nil {
+		r.resolveExpr(expr.end)
+	}
+}
+
+// visitMapLiteralExpr implements ExprVisitor.
+func (r *Resolver) visitMapLiteralExpr(expr *MapLiteral) {
+	for idx := range expr.keys {
+		r.resolveExpr(expr.keys[idx])
+		r.resolveExpr(expr.values[idx])
+	}
+}
+
+// visitLambdaExpr implements ExprVisitor.
+func (r *Resolver) visitLambdaExpr(expr *Lambda) {
+	r.resolveFunction(expr.decl, FUNCTION)
+}
+
 // visitBlockStmt implements StmtVisitor.
 func (r *Resolver) visitBlockStmt(stmt *BlockStmt) {
 	r.beginScope()
@@ -61,7 +191,7 @@ func (r *Resolver) visitBlockStmt(stmt *BlockStmt) {
 }
 
 func (r *Resolver) beginScope() {
-	r.scopes.Push(make(map[string]bool))
+	r.scopes.Push(make(map[string]*binding))
 }
 
 func (r *Resolver) endScope() {
@@ -69,13 +199,17 @@ func (r *Resolver) endScope() {
 }
 
 func (r *Resolver) declare(name Token) {
-	// Are we getting a reference to the map[string]bool on the stack,
-	// or a copy?
+	// Maps are reference types, so this is the same map
+	// that is on the stack rather than a copy
 	scope, err := r.scopes.Peek()
 	if err != nil {
 		return
 	}
-	scope[name.Lexeme] = false
+	if _, ok := scope[name.Lexeme]; ok {
+		r.error(name, "Already a variable with this name in this scope")
+		return
+	}
+	scope[name.Lexeme] = &binding{slot: len(scope)}
 }
 
 func (r *Resolver) define(name Token) {
@@ -84,17 +218,49 @@ func (r *Resolver) define(name Token) {
 		return
 	}
 	// Variable is initialised
-	scope[name.Lexeme] = true
+	if b, ok := scope[name.Lexeme]; ok {
+		b.defined = true
+	}
 }
 
 func (r *Resolver) resolve(statements []Stmt) {
 	for _, stmt := range statements {
-		resolveStmt(stmt)
+		r.resolveStmt(stmt)
 	}
 }
 
+// resolveLocal walks the scope stack from the innermost scope outwards,
+// and tells the interpreter how many scopes lie between the expression
+// and the variable it refers to, and its slot in that scope. Variables
+// that aren't found are assumed to be global.
 func (r *Resolver) resolveLocal(expr Expr, name Token) {
-	// TODO: Walk stack to find variable and then resolve it
+	for depth := 0; depth < r.scopes.Size(); depth++ {
+		if b, ok := r.scopes.Get(depth)[name.Lexeme]; ok {
+			if r.interpreter != nil {
+				r.interpreter.resolve(expr, depth, b.slot)
+			}
+			return
+		}
+	}
+}
+
+func (r *Resolver) resolveFunction(function *FunctionStmt, ftype FunctionType) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = ftype
+	// Loops don't extend into function bodies
+	enclosingLoopDepth := r.loopDepth
+	r.loopDepth = 0
+
+	r.beginScope()
+	for _, param := range function.params {
+		r.declare(param)
+		r.define(param)
+	}
+	r.resolve(function.body)
+	r.endScope()
+
+	r.currentFunction = enclosingFunction
+	r.loopDepth = enclosingLoopDepth
 }
 
 func (r *Resolver) resolveStmt(stmt Stmt) {
@@ -106,28 +272,83 @@ func (r *Resolver) resolveExpr(expr Expr) {
 }
 
 // visitExpressionStmt implements StmtVisitor.
-func (*Resolver) visitExpressionStmt(*ExpressionStmt) {
-	panic("unimplemented")
+func (r *Resolver) visitExpressionStmt(stmt *ExpressionStmt) {
+	r.resolveExpr(stmt.Expr)
 }
 
 // visitFunctionStmt implements StmtVisitor.
-func (*Resolver) visitFunctionStmt(*FunctionStmt) {
-	panic("unimplemented")
+func (r *Resolver) visitFunctionStmt(stmt *FunctionStmt) {
+	// Define the name eagerly so that the function can refer
+	// to itself recursively
+	r.declare(stmt.name)
+	r.define(stmt.name)
+	r.resolveFunction(stmt, FUNCTION)
 }
 
 // visitIfStmt implements StmtVisitor.
-func (*Resolver) visitIfStmt(*IfStmt) {
-	panic("unimplemented")
+func (r *Resolver) visitIfStmt(stmt *IfStmt) {
+	r.resolveExpr(stmt.expr)
+	r.resolveStmt(stmt.thenBranch)
+	if stmt.elseBranch != nil {
+		r.resolveStmt(stmt.elseBranch)
+	}
 }
 
 // visitPrintStmt implements StmtVisitor.
-func (*Resolver) visitPrintStmt(*PrintStmt) {
-	panic("unimplemented")
+func (r *Resolver) visitPrintStmt(stmt *PrintStmt) {
+	r.resolveExpr(stmt.Expr)
 }
 
 // visitReturnStmt implements StmtVisitor.
-func (*Resolver) visitReturnStmt(*ReturnStmt) {
-	panic("unimplemented")
+func (r *Resolver) visitReturnStmt(stmt *ReturnStmt) {
+	if r.currentFunction == NONE {
+		r.error(stmt.keyword, "Can't return from top-level code")
+	}
+	if stmt.value != nil {
+		if r.currentFunction == INITIALISER {
+			r.error(stmt.keyword, "Can't return a value from an initialiser")
+		}
+		r.resolveExpr(stmt.value)
+	}
+}
+
+// visitClassStmt implements StmtVisitor.
+func (r *Resolver) visitClassStmt(stmt *ClassStmt) {
+	enclosingClass := r.currentClass
+	r.currentClass = IN_CLASS
+
+	r.declare(stmt.name)
+	r.define(stmt.name)
+
+	if stmt.superclass != nil {
+		r.currentClass = IN_SUBCLASS
+		r.resolveExpr(stmt.superclass)
+
+		// Methods of a subclass close over an extra
+		// scope which binds 'super'
+		r.beginScope()
+		scope, _ := r.scopes.Peek()
+		scope["super"] = &binding{slot: 0, defined: true}
+	}
+
+	// Methods close over a scope which binds 'this'
+	r.beginScope()
+	scope, _ := r.scopes.Peek()
+	scope["this"] = &binding{slot: 0, defined: true}
+	for _, method := range stmt.methods {
+		ftype := METHOD
+		if method.name.Lexeme == "init" {
+			ftype = INITIALISER
+		}
+		r.resolveFunction(method, ftype)
+	}
+	r.endScope()
+
+	if stmt.superclass != nil {
+		r.endScope()
+	}
+
+	r.currentClass = enclosingClass
 }
 
 // visitVarStmt implements StmtVisitor.
@@ -140,6 +361,67 @@ func (r *Resolver) visitVarStmt(stmt *VarStmt) {
 }
 
 // visitWhileStmt implements StmtVisitor.
-func (*Resolver) visitWhileStmt(*WhileStmt) {
-	panic("unimplemented")
+func (r *Resolver) visitWhileStmt(stmt *WhileStmt) {
+	r.resolveExpr(stmt.expr)
+	r.loopDepth++
+	r.resolveStmt(stmt.body)
+	r.loopDepth--
+	if stmt.increment != nil {
+		r.resolveExpr(stmt.increment)
+	}
+}
+
+// visitForInStmt implements StmtVisitor.
+func (r *Resolver) visitForInStmt(stmt *ForInStmt) {
+	r.resolveExpr(stmt.iterable)
+
+	// The loop variable is bound in a fresh scope each iteration
+	r.beginScope()
+	r.declare(stmt.name)
+	r.define(stmt.name)
+	r.loopDepth++
+	r.resolveStmt(stmt.body)
+	r.loopDepth--
+	r.endScope()
+}
+
+// visitThrowStmt implements StmtVisitor.
+func (r *Resolver) visitThrowStmt(stmt *ThrowStmt) {
+	r.resolveExpr(stmt.value)
+}
+
+// visitTryStmt implements StmtVisitor.
+func (r *Resolver) visitTryStmt(stmt *TryStmt) {
+	r.beginScope()
+	r.resolve(stmt.body)
+	r.endScope()
+
+	if stmt.catchBody != nil {
+		// The caught value is bound in the scope of the catch block
+		r.beginScope()
+		r.declare(stmt.catchName)
+		r.define(stmt.catchName)
+		r.resolve(stmt.catchBody)
+		r.endScope()
+	}
+
+	if stmt.finallyBody != nil {
+		r.beginScope()
+		r.resolve(stmt.finallyBody)
+		r.endScope()
+	}
+}
+
+// visitBreakStmt implements StmtVisitor.
+func (r *Resolver) visitBreakStmt(stmt *BreakStmt) {
+	if r.loopDepth == 0 {
+		r.error(stmt.keyword, "Can't use 'break' outside of a loop")
+	}
+}
+
+// visitContinueStmt implements StmtVisitor.
+func (r *Resolver) visitContinueStmt(stmt *ContinueStmt) {
+	if r.loopDepth == 0 {
+		r.error(stmt.keyword, "Can't use 'continue' outside of a loop")
+	}
 }
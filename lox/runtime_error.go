@@ -0,0 +1,32 @@
+package lox
+
+type RuntimeError struct {
+	token Token
+	msg   string
+	// Set once the error is known to be uncaught
+	trace []StackFrame
+}
+
+func (err RuntimeError) Error() string {
+	return err.msg
+}
+
+// Line is the line of the token where the error occurred
+func (err RuntimeError) Line() int {
+	return err.token.Line
+}
+
+// Trace is the traceback of calls that led to an uncaught
+// error, with the most recent call last
+func (err RuntimeError) Trace() []StackFrame {
+	return err.trace
+}
+
+// Diagnostic describes the error, pointing at the token
+// where it occurred
+func (err RuntimeError) Diagnostic() *Diagnostic {
+	d := ErrorOnToken(CodeRuntime, err.token, err.msg)
+	d.Trace = err.trace
+	d.cause = err
+	return d
+}
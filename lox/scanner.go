@@ -3,46 +3,71 @@ package lox
 import (
 	"fmt"
 	"strconv"
+	"unicode/utf8"
 )
 
 var keywords = map[string]TokenType {
 	"and":    AND,
+	"break":  BREAK,
+	"catch":  CATCH,
 	"class":  CLASS,
+	"continue": CONTINUE,
 	"else":   ELSE,
 	"false":  FALSE,
+	"finally": FINALLY,
 	"for":    FOR,
 	"fun":    FUN,
 	"if":     IF,
+	"in":     IN,
 	"nil":    NIL,
 	"or":     OR,
 	"print":  PRINT,
 	"return": RETURN,
 	"super":  SUPER,
 	"this":   THIS,
+	"throw":  THROW,
 	"true":   TRUE,
+	"try":    TRY,
 	"var":    VAR,
 	"while":  WHILE,
 }
 
 type Scanner struct {
-	Source string
+	Source *Source
 	tokens []Token
+	errors Diagnostics
 	start int
 	current int
 	line int
+	// Character column of the current position, counted
+	// as characters are consumed
+	column int
+	// Position of the start of the current token
+	startLine int
+	startColumn int
 }
 
-func NewScanner(source string) *Scanner {
-	return &Scanner{Source: source, start: 0, current: 0, line: 1}
+// NewScanner creates a scanner for source, where name identifies the
+// source in diagnostics, e.g. a file path
+func NewScanner(name string, source string) *Scanner {
+	return &Scanner{Source: &Source{Name: name, Text: source}, start: 0, current: 0, line: 1, column: 1}
 }
 
-func (s *Scanner) ScanTokens() []Token {
+// ScanTokens returns the tokens in the source. Any errors are
+// returned together as Diagnostics, alongside the tokens that
+// could be scanned.
+func (s *Scanner) ScanTokens() ([]Token, error) {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startLine = s.line
+		s.startColumn = s.column
 		s.scanToken()
 	}
-	s.tokens = append(s.tokens, Token{Type: EOF, Lexeme: "", Literal: nil, Line: s.line})
-	return s.tokens
+	s.start = s.current
+	s.startLine = s.line
+	s.startColumn = s.column
+	s.addToken(EOF)
+	return s.tokens, s.errors.errorOrNil()
 }
 
 func (s *Scanner) scanToken() {
@@ -52,6 +77,9 @@ func (s *Scanner) scanToken() {
 		case ')': s.addToken(RIGHT_PAREN)
 		case '{': s.addToken(LEFT_BRACE)
 		case '}': s.addToken(RIGHT_BRACE)
+		case '[': s.addToken(LEFT_BRACKET)
+		case ']': s.addToken(RIGHT_BRACKET)
+		case ':': s.addToken(COLON)
 		case ',': s.addToken(COMMA)
 		case '.': s.addToken(DOT)
 		case '-': s.addToken(MINUS)
@@ -68,6 +96,8 @@ func (s *Scanner) scanToken() {
 			token := EQUAL
 			if s.match('=') {
 				token = EQUAL_EQUAL
+			} else if s.match('>') {
+				token = ARROW
 			}
 			s.addToken(token)
 		case '<':
@@ -95,7 +125,7 @@ func (s *Scanner) scanToken() {
 		case ' ':
 		case '\r':
 		case '\t':
-		case '\n': s.line += 1
+		case '\n': s.newline()
 		case '"': s.string()
 		default: // This is checked here to save adding a case for each digit
 			if isDigit(c) {
@@ -103,53 +133,77 @@ func (s *Scanner) scanToken() {
 			} else if isAlpha(c) {
 				s.identifier()
 			} else {
-				Error(s.line, "Unexpected character")
+				s.error("Unexpected character")
 			}
 	}
 }
 
 
+// error records a diagnostic spanning the current token
+func (s *Scanner) error(msg string) *Diagnostic {
+	err := ErrorOnToken(CodeScan, s.token(EOF, nil), msg)
+	s.errors = append(s.errors, err)
+	return err
+}
+
+func (s *Scanner) newline() {
+	s.line += 1
+	s.column = 1
+}
+
 func (s *Scanner) isAtEnd() bool {
-	return s.current >= len(s.Source)
+	return s.current >= len(s.Source.Text)
 }
 
+// advance consumes a whole UTF-8 encoded character
 func (s *Scanner) advance() rune {
-	current := s.current
-	s.current += 1
-	return rune(s.Source[current])
+	c, size := utf8.DecodeRuneInString(s.Source.Text[s.current:])
+	s.current += size
+	s.column++
+	return c
+}
+
+func (s *Scanner) previous() rune {
+	c, _ := utf8.DecodeLastRuneInString(s.Source.Text[:s.current])
+	return c
 }
 
 func (s *Scanner) match(expected rune) bool {
 	if s.isAtEnd() { return false }
-	if rune(s.Source[s.current]) != expected { return false }
+	if s.peek() != expected { return false }
 
-	s.current += 1
+	s.advance()
 	return true
 }
 
 func (s *Scanner) peek() rune {
 	if s.isAtEnd() { return '\u0000' }
-	return rune(s.Source[s.current])
+	c, _ := utf8.DecodeRuneInString(s.Source.Text[s.current:])
+	return c
 }
 
 func (s *Scanner) peekNext() rune {
-	if s.current + 1 >= len(s.Source) { return '\u0000' }
-	return rune(s.Source[s.current+1])
+	if s.isAtEnd() { return '\u0000' }
+	_, size := utf8.DecodeRuneInString(s.Source.Text[s.current:])
+	if s.current + size >= len(s.Source.Text) { return '\u0000' }
+	c, _ := utf8.DecodeRuneInString(s.Source.Text[s.current+size:])
+	return c
 }
 
 func (s *Scanner) string() {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' { s.line += 1 }
 		s.advance()
+		if s.previous() == '\n' { s.newline() }
 	}
 
 	if s.isAtEnd() {
-		Error(s.line, "Unterminated string")
+		err := s.error("Unterminated string")
+		err.Notes = append(err.Notes, "the string continues to the end of the file")
 		return
 	}
 
 	s.advance() // Closing "
-	value := s.Source[s.start+1:s.current-1]
+	value := s.Source.Text[s.start+1:s.current-1]
 	s.addTokenWithLiteral(STRING, value)
 }
 
@@ -160,9 +214,9 @@ func (s *Scanner) number() {
 		s.advance()
 		for isDigit(s.peek()) { s.advance() }
 	}
-	f64, err := strconv.ParseFloat(s.Source[s.start:s.current], 64)
+	f64, err := strconv.ParseFloat(s.Source.Text[s.start:s.current], 64)
 	if err != nil {
-		Error(s.line, fmt.Sprint(err))
+		s.error(fmt.Sprint(err))
 	} else {
 		s.addTokenWithLiteral(NUMBER, f64)
 	}
@@ -171,7 +225,7 @@ func (s *Scanner) number() {
 
 func (s *Scanner) identifier() {
 	for isAlphaNumeric(s.peek()) { s.advance() }
-	text := s.Source[s.start:s.current]
+	text := s.Source.Text[s.start:s.current]
 	tokenType, ok := keywords[text]
 	if ok {
 		s.addToken(tokenType)
@@ -185,8 +239,23 @@ func (s *Scanner) addToken(t TokenType) {
 }
 
 func (s *Scanner) addTokenWithLiteral(t TokenType, literal any) {
-	text := s.Source[s.start:s.current]
-	s.tokens = append(s.tokens, Token{Type: t, Lexeme: text, Literal: literal, Line: s.line})
+	s.tokens = append(s.tokens, s.token(t, literal))
+}
+
+// token creates a token spanning from the start of the
+// current lexeme to the current position
+func (s *Scanner) token(t TokenType, literal any) Token {
+	text := s.Source.Text[s.start:s.current]
+	return Token{
+		Type: t,
+		Lexeme: text,
+		Literal: literal,
+		Line: s.startLine,
+		Offset: s.start,
+		Column: s.startColumn,
+		Length: len(text),
+		source: s.Source,
+	}
 }
 
 func isDigit(c rune) bool {
@@ -0,0 +1,503 @@
+package lox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// A compiled program file is laid out as:
+//
+//	magic    "LOXC"
+//	version  uint16
+//	source   name and text, for diagnostics
+//	function the top-level code
+//	checksum CRC-32 of everything before it, uint32
+//
+// Integers are big-endian, or unsigned varints where noted below.
+const (
+	programMagic = "LOXC"
+	// Bump this whenever the instruction set or layout changes
+	programVersion = 1
+)
+
+// Tags for the values in a constant pool
+const (
+	constantNumber byte = iota
+	constantString
+	constantFunction
+)
+
+// ErrBadProgram is wrapped by the errors ReadProgram returns for
+// files which aren't valid compiled programs
+var ErrBadProgram = errors.New("invalid compiled program")
+
+// Functions nest no deeper than this in a valid file
+const maxFunctionDepth = 1000
+
+// WriteTo writes the program to w in the compiled program format,
+// which ReadProgram reads back. It implements io.WriterTo.
+func (p *Program) WriteTo(w io.Writer) (int64, error) {
+	buf := []byte(programMagic)
+	buf = binary.BigEndian.AppendUint16(buf, programVersion)
+	source := p.function.chunk.source
+	if source == nil {
+		source = &Source{}
+	}
+	buf = appendString(buf, source.Name)
+	buf = appendString(buf, source.Text)
+	buf = appendFunction(buf, p.function)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendFunction appends the name, arity, upvalue count, code,
+// positions and constants of function, as uvarints except for
+// the code itself
+func appendFunction(buf []byte, function *funcProto) []byte {
+	chunk := function.chunk
+	buf = appendString(buf, function.name)
+	buf = binary.AppendUvarint(buf, uint64(function.arity))
+	buf = binary.AppendUvarint(buf, uint64(function.upvalueCount))
+	buf = binary.AppendUvarint(buf, uint64(len(chunk.code)))
+	buf = append(buf, chunk.code...)
+
+	// Each instruction's bytes share a position, so
+	// they are stored as runs
+	type run struct {
+		count int
+		pos   position
+	}
+	var runs []run
+	for _, pos := range chunk.positions {
+		if len(runs) > 0 && runs[len(runs)-1].pos == pos {
+			runs[len(runs)-1].count++
+		} else {
+			runs = append(runs, run{count: 1, pos: pos})
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(runs)))
+	for _, r := range runs {
+		for _, n := range []int{r.count, r.pos.line, r.pos.column, r.pos.offset, r.pos.length} {
+			buf = binary.AppendUvarint(buf, uint64(n))
+		}
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(chunk.constants)))
+	for _, constant := range chunk.constants {
+		switch c := constant.(type) {
+		case float64:
+			buf = append(buf, constantNumber)
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(c))
+		case string:
+			buf = append(buf, constantString)
+			buf = appendString(buf, c)
+		case *funcProto:
+			buf = append(buf, constantFunction)
+			buf = appendFunction(buf, c)
+		default:
+			panic(fmt.Sprintf("unexpected constant %v", constant))
+		}
+	}
+	return buf
+}
+
+// ReadProgram reads a program written by Program.WriteTo. Files with
+// the wrong header or version, or which are corrupted or malformed,
+// are rejected with an error wrapping ErrBadProgram.
+func ReadProgram(r io.Reader) (*Program, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(programMagic) + 2
+	if len(data) < headerSize || string(data[:len(programMagic)]) != programMagic {
+		return nil, fmt.Errorf("%w: not a compiled Lox file", ErrBadProgram)
+	}
+	if version := binary.BigEndian.Uint16(data[len(programMagic):]); version != programVersion {
+		return nil, fmt.Errorf("%w: format version %d is not supported, expected version %d",
+			ErrBadProgram, version, programVersion)
+	}
+	if len(data) < headerSize+4 {
+		return nil, fmt.Errorf("%w: file is truncated", ErrBadProgram)
+	}
+	body, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch, the file is corrupted", ErrBadProgram)
+	}
+
+	d := &decoder{data: body[headerSize:]}
+	source := &Source{Name: d.string(), Text: d.string()}
+	d.source = source
+	function := d.function(0)
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("unexpected data after the program")
+	}
+	// The Machine runs the top-level code without arguments or upvalues
+	if d.err == nil && (function.arity != 0 || function.upvalueCount != 0) {
+		d.fail("top-level code can't have parameters or upvalues")
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadProgram, d.err)
+	}
+	return &Program{function: function}, nil
+}
+
+// decoder reads the body of a compiled program. After the first
+// error it reads zero values, so that callers need only check err
+// at the end.
+type decoder struct {
+	data   []byte
+	source *Source
+	err    error
+}
+
+func (d *decoder) fail(msg string) {
+	if d.err == nil {
+		d.err = errors.New(msg)
+	}
+	d.data = nil
+}
+
+func (d *decoder) uvarint() int {
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 || n > math.MaxInt32 {
+		d.fail("file is truncated")
+		return 0
+	}
+	d.data = d.data[size:]
+	return int(n)
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if n > len(d.data) {
+		d.fail("file is truncated")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.uvarint()))
+}
+
+func (d *decoder) function(depth int) *funcProto {
+	if depth > maxFunctionDepth {
+		d.fail("functions are nested too deeply")
+		return nil
+	}
+	function := &funcProto{name: d.string(), arity: d.uvarint(), upvalueCount: d.uvarint()}
+	chunk := &Chunk{source: d.source}
+	function.chunk = chunk
+	chunk.code = append([]byte{}, d.bytes(d.uvarint())...)
+
+	runs := d.uvarint()
+	for idx := 0; idx < runs && d.err == nil; idx++ {
+		count := d.uvarint()
+		pos := position{line: d.uvarint(), column: d.uvarint(), offset: d.uvarint(), length: d.uvarint()}
+		if len(chunk.positions)+count > len(chunk.code) {
+			d.fail("more positions than instructions")
+			break
+		}
+		for n := 0; n < count; n++ {
+			chunk.positions = append(chunk.positions, pos)
+		}
+	}
+
+	constants := d.uvarint()
+	for idx := 0; idx < constants && d.err == nil; idx++ {
+		switch tag := d.bytes(1); {
+		case len(tag) == 0:
+		case tag[0] == constantNumber:
+			if bits := d.bytes(8); len(bits) == 8 {
+				chunk.constants = append(chunk.constants, math.Float64frombits(binary.BigEndian.Uint64(bits)))
+			}
+		case tag[0] == constantString:
+			chunk.constants = append(chunk.constants, d.string())
+		case tag[0] == constantFunction:
+			chunk.constants = append(chunk.constants, d.function(depth+1))
+		default:
+			d.fail(fmt.Sprintf("unknown constant type %d", tag[0]))
+		}
+	}
+
+	if d.err == nil {
+		if err := verifyChunk(function); err != nil {
+			d.fail(fmt.Sprintf("in %s: %s", function, err))
+		}
+	}
+	return function
+}
+
+// verifyChunk checks that the instructions of function can be run
+// without reading outside of the code, constant pool or stack. The
+// types of values on the stack can't all be known before running, so
+// the Machine checks those it relies on as it goes.
+func verifyChunk(function *funcProto) error {
+	chunk := function.chunk
+	if len(chunk.positions) != len(chunk.code) {
+		return errors.New("fewer positions than instructions")
+	}
+	if len(chunk.code) == 0 {
+		return errors.New("function has no code")
+	}
+	constant := func(offset int, check func(any) bool) error {
+		idx := chunk.readShort(offset)
+		if idx >= len(chunk.constants) || !check(chunk.constants[idx]) {
+			return fmt.Errorf("bad constant %d at offset %d", idx, offset)
+		}
+		return nil
+	}
+	isName := func(value any) bool {
+		_, ok := value.(string)
+		return ok
+	}
+
+	// The size of the instruction at each offset, or zero
+	// for offsets in the middle of an instruction
+	sizes := make([]int, len(chunk.code))
+	for offset := 0; offset < len(chunk.code); {
+		op := OpCode(chunk.code[offset])
+		size := 1
+		switch op {
+		case OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_CALL:
+			size = 2
+		case OP_CONSTANT, OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL, OP_GET_PROPERTY,
+			OP_SET_PROPERTY, OP_GET_SUPER, OP_CLASS, OP_METHOD, OP_LIST, OP_MAP,
+			OP_JUMP, OP_JUMP_IF_FALSE, OP_LOOP, OP_FOR_ITER, OP_UNWIND, OP_CLOSURE:
+			size = 3
+		case OP_TRY:
+			size = 4
+		default:
+			if int(op) >= len(opNames) {
+				return fmt.Errorf("unknown opcode %d at offset %d", op, offset)
+			}
+		}
+		if offset+size > len(chunk.code) {
+			return fmt.Errorf("truncated instruction at offset %d", offset)
+		}
+
+		var err error
+		switch op {
+		case OP_CONSTANT:
+			err = constant(offset+1, func(value any) bool {
+				_, ok := value.(*funcProto)
+				return !ok
+			})
+		case OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL, OP_GET_PROPERTY,
+			OP_SET_PROPERTY, OP_GET_SUPER, OP_CLASS, OP_METHOD:
+			err = constant(offset+1, isName)
+		case OP_CLOSURE:
+			err = constant(offset+1, func(value any) bool {
+				_, ok := value.(*funcProto)
+				return ok
+			})
+			if err == nil {
+				upvalues := chunk.constants[chunk.readShort(offset+1)].(*funcProto).upvalueCount
+				size += 2 * upvalues
+				if offset+size > len(chunk.code) {
+					return fmt.Errorf("truncated instruction at offset %d", offset)
+				}
+				for idx := offset + 3; idx < offset+size; idx += 2 {
+					isLocal, index := chunk.code[idx], int(chunk.code[idx+1])
+					if isLocal > 1 || isLocal == 0 && index >= function.upvalueCount {
+						return fmt.Errorf("bad upvalue at offset %d", idx)
+					}
+				}
+			}
+		case OP_GET_UPVALUE, OP_SET_UPVALUE:
+			if int(chunk.code[offset+1]) >= function.upvalueCount {
+				err = fmt.Errorf("bad upvalue at offset %d", offset)
+			}
+		case OP_TRY:
+			if kind := chunk.code[offset+1]; kind != handlerCatch && kind != handlerFinally {
+				err = fmt.Errorf("unknown handler kind %d at offset %d", kind, offset)
+			}
+		case OP_UNWIND:
+			if chunk.code[offset+2] > 1 {
+				err = fmt.Errorf("bad operand at offset %d", offset)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		sizes[offset] = size
+		offset += size
+	}
+	return verifyStack(function, sizes)
+}
+
+// stackState is what verifyStack knows about the stack before an
+// instruction: the number of values above the frame's base, and the
+// exception handlers that the function has pushed
+type stackState struct {
+	height   int
+	handlers *handlerState
+}
+
+// handlerState is an exception handler pushed by OP_TRY, which
+// restores the stack to height sp when it catches an exception
+type handlerState struct {
+	sp    int
+	below *handlerState
+}
+
+// verifyStack follows every path through the code of function, given
+// the size of each instruction. Wherever paths meet, the stack must
+// have the same height and handlers, so that loops can't grow it.
+// Instructions must only take values and use local slots which are
+// on the stack, and handlers must be popped before returning.
+func verifyStack(function *funcProto, sizes []int) error {
+	chunk := function.chunk
+	code := chunk.code
+	states := make([]stackState, len(code))
+	seen := make([]bool, len(code))
+	// Equal stacks of handlers are the same pointer, so that
+	// states can be compared with ==
+	handlers := make(map[handlerState]*handlerState)
+	pushHandler := func(below *handlerState, sp int) *handlerState {
+		key := handlerState{sp: sp, below: below}
+		h, ok := handlers[key]
+		if !ok {
+			h = &handlerState{sp: sp, below: below}
+			handlers[key] = h
+		}
+		return h
+	}
+
+	var work []int
+	reach := func(offset int, state stackState) error {
+		if offset < 0 || offset >= len(code) {
+			return fmt.Errorf("code runs past the end at offset %d", offset)
+		}
+		if sizes[offset] == 0 {
+			return fmt.Errorf("jump into the middle of an instruction at offset %d", offset)
+		}
+		if !seen[offset] {
+			seen[offset] = true
+			states[offset] = state
+			work = append(work, offset)
+		} else if states[offset] != state {
+			return fmt.Errorf("stack differs between paths to offset %d", offset)
+		}
+		return nil
+	}
+
+	// Slot 0 holds the function itself, or 'this'
+	if err := reach(0, stackState{height: function.arity + 1}); err != nil {
+		return err
+	}
+	for len(work) > 0 {
+		offset := work[len(work)-1]
+		work = work[:len(work)-1]
+		state := states[offset]
+		op := OpCode(code[offset])
+		next := offset + sizes[offset]
+
+		// How many values the instruction takes from the stack and
+		// puts back, and whether the next one can follow it
+		pops, pushes, falls := 0, 0, true
+		var err error
+		switch op {
+		case OP_CONSTANT, OP_NIL, OP_TRUE, OP_FALSE, OP_GET_GLOBAL, OP_GET_UPVALUE, OP_CLASS:
+			pushes = 1
+		case OP_GET_LOCAL, OP_SET_LOCAL:
+			if slot := int(code[offset+1]); slot >= state.height {
+				err = fmt.Errorf("bad local slot %d at offset %d", slot, offset)
+			}
+			pops, pushes = 1, 1
+			if op == OP_GET_LOCAL {
+				pops = 0
+			}
+		case OP_CLOSURE:
+			for idx := offset + 3; idx < next; idx += 2 {
+				if code[idx] == 1 && int(code[idx+1]) >= state.height {
+					err = fmt.Errorf("bad upvalue at offset %d", idx)
+				}
+			}
+			pushes = 1
+		case OP_POP, OP_DEFINE_GLOBAL, OP_PRINT, OP_CLOSE_UPVALUE:
+			pops = 1
+		case OP_SET_GLOBAL, OP_SET_UPVALUE, OP_GET_PROPERTY, OP_NOT, OP_NEGATE,
+			OP_JUMP_IF_FALSE, OP_CHECK_SUPERCLASS, OP_ITER:
+			pops, pushes = 1, 1
+		case OP_SET_PROPERTY, OP_GET_SUPER, OP_EQUAL, OP_NOT_EQUAL, OP_GREATER, OP_GREATER_EQUAL,
+			OP_LESS, OP_LESS_EQUAL, OP_ADD, OP_SUBTRACT, OP_MULTIPLY, OP_DIVIDE,
+			OP_INHERIT, OP_METHOD, OP_INDEX:
+			pops, pushes = 2, 1
+		case OP_SET_INDEX, OP_SLICE:
+			pops, pushes = 3, 1
+		case OP_CALL:
+			pops, pushes = int(code[offset+1])+1, 1
+		case OP_LIST:
+			pops, pushes = chunk.readShort(offset+1), 1
+		case OP_MAP:
+			pops, pushes = 2*chunk.readShort(offset+1), 1
+		case OP_FOR_ITER:
+			// Pushes the next value, or jumps if there are none
+			pops, pushes = 1, 2
+		case OP_RETURN:
+			if state.handlers != nil {
+				err = fmt.Errorf("return with a handler still pushed at offset %d", offset)
+			}
+			pops, falls = 1, false
+		case OP_THROW, OP_RETHROW:
+			pops, falls = 1, false
+		case OP_JUMP, OP_LOOP:
+			falls = false
+		case OP_UNWIND:
+			// Keeps the top of the stack above the first count slots
+			count, keep := int(code[offset+1]), int(code[offset+2])
+			if state.height == 0 || count+keep > state.height {
+				err = fmt.Errorf("bad unwind at offset %d", offset)
+			}
+			pops, pushes = state.height, count+keep
+		}
+		if err != nil {
+			return err
+		}
+		if pops > state.height {
+			return fmt.Errorf("stack underflow at offset %d", offset)
+		}
+		after := stackState{height: state.height - pops + pushes, handlers: state.handlers}
+
+		switch op {
+		case OP_JUMP, OP_JUMP_IF_FALSE:
+			err = reach(next+chunk.readShort(offset+1), after)
+		case OP_FOR_ITER:
+			err = reach(next+chunk.readShort(offset+1), stackState{height: state.height, handlers: state.handlers})
+		case OP_LOOP:
+			err = reach(next-chunk.readShort(offset+1), after)
+		case OP_TRY:
+			// The handler is reached with the exception pushed
+			err = reach(next+chunk.readShort(offset+2), stackState{height: state.height + 1, handlers: state.handlers})
+			after.handlers = pushHandler(state.handlers, state.height)
+		case OP_END_TRY:
+			if state.handlers == nil {
+				return fmt.Errorf("no handler to pop at offset %d", offset)
+			}
+			after.handlers = state.handlers.below
+		}
+		if err != nil {
+			return err
+		}
+		if falls {
+			if err := reach(next, after); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package lox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+const serialiseSource = `
+	class Counter {
+		init() { this.n = 0; }
+		inc() { this.n = this.n + 1; return this.n; }
+	}
+	fun run() {
+		var c = Counter();
+		for (var x in [1, 2, 3]) {
+			try {
+				if (x == 2) throw Error("two");
+				c.inc();
+			} catch (e) {
+				print e.message;
+			} finally {
+				print x;
+			}
+		}
+		return c.n;
+	}
+	print run();`
+
+// compiled returns the serialiseSource program in the file format
+func compiled(t *testing.T) []byte {
+	t.Helper()
+	p, err := Compile("test.lox", serialiseSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProgramRoundTrip(t *testing.T) {
+	p, err := ReadProgram(bytes.NewReader(compiled(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	vm := NewVM(&stdout, &stdout, WithBackend(Bytecode))
+	if _, err := vm.Run(p); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1\ntwo\n2\n3\n2\n"; stdout.String() != want {
+		t.Errorf("printed %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestReadBadProgram(t *testing.T) {
+	data := compiled(t)
+	// withChecksum replaces the checksum of a changed file
+	withChecksum := func(data []byte) []byte {
+		body := data[:len(data)-4]
+		return binary.BigEndian.AppendUint32(append([]byte{}, body...), crc32.ChecksumIEEE(body))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte("LOXX"), data[4:]...)},
+		{"wrong version", withChecksum(append(append([]byte("LOXC"), 0, 99), data[6:]...))},
+		{"truncated", data[:len(data)/2]},
+		{"truncated with checksum", withChecksum(data[:len(data)/2])},
+		{"flipped byte", func() []byte {
+			flipped := append([]byte{}, data...)
+			flipped[len(flipped)/2] ^= 0xff
+			return flipped
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadProgram(bytes.NewReader(tt.data))
+			if !errors.Is(err, ErrBadProgram) {
+				t.Errorf("got %v, want ErrBadProgram", err)
+			}
+		})
+	}
+}
+
+// TestReadMalformedProgram writes programs with bad code, which
+// ReadProgram should reject rather than leave to crash the Machine
+func TestReadMalformedProgram(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+	}{
+		{"local beyond the stack", []byte{byte(OP_GET_LOCAL), 3, byte(OP_RETURN)}},
+		{"stack underflow", []byte{byte(OP_POP), byte(OP_POP), byte(OP_NIL), byte(OP_RETURN)}},
+		{"unwind beyond the stack", []byte{byte(OP_NIL), byte(OP_UNWIND), 5, 1, byte(OP_RETURN)}},
+		{"bad unwind flag", []byte{byte(OP_NIL), byte(OP_UNWIND), 0, 2, byte(OP_RETURN)}},
+		{"capture beyond the stack", nil},
+		{"end try without try", []byte{byte(OP_END_TRY), byte(OP_NIL), byte(OP_RETURN)}},
+		{"return inside try", []byte{byte(OP_TRY), handlerCatch, 0, 2, byte(OP_NIL), byte(OP_RETURN), byte(OP_RETURN)}},
+		{"unknown handler kind", []byte{byte(OP_TRY), 7, 0, 0, byte(OP_END_TRY), byte(OP_NIL), byte(OP_RETURN)}},
+		{"jump into an instruction", []byte{byte(OP_JUMP), 0, 1, byte(OP_GET_LOCAL), byte(OP_RETURN), byte(OP_RETURN)}},
+		{"loop growing the stack", []byte{byte(OP_NIL), byte(OP_LOOP), 0, 4}},
+		{"runs off the end", []byte{byte(OP_NIL)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			function := &funcProto{chunk: &Chunk{code: tt.code}}
+			if tt.code == nil {
+				// A closure capturing slot 9 of its enclosing function
+				inner := &funcProto{name: "f", upvalueCount: 1, chunk: &Chunk{code: []byte{byte(OP_NIL), byte(OP_RETURN)}}}
+				inner.chunk.positions = make([]position, len(inner.chunk.code))
+				function.chunk.constants = []any{inner}
+				function.chunk.code = []byte{byte(OP_CLOSURE), 0, 0, 1, 9, byte(OP_RETURN)}
+			}
+			function.chunk.positions = make([]position, len(function.chunk.code))
+
+			var buf bytes.Buffer
+			if _, err := (&Program{function: function}).WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadProgram(&buf)
+			if !errors.Is(err, ErrBadProgram) {
+				t.Errorf("got %v, want ErrBadProgram", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package lox
+
+import "errors"
+
+var errEmptyStack = errors.New("stack is empty")
+
+// Stack is a simple LIFO stack backed by a slice
+type Stack[T any] struct {
+	items []T
+}
+
+func NewStack[T any]() *Stack[T] {
+	return &Stack[T]{}
+}
+
+func (s *Stack[T]) Push(item T) {
+	s.items = append(s.items, item)
+}
+
+func (s *Stack[T]) Pop() (T, error) {
+	var item T
+	if s.IsEmpty() {
+		return item, errEmptyStack
+	}
+	item = s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return item, nil
+}
+
+func (s *Stack[T]) Peek() (T, error) {
+	var item T
+	if s.IsEmpty() {
+		return item, errEmptyStack
+	}
+	return s.items[len(s.items)-1], nil
+}
+
+// Get returns the item i places from the top of the stack,
+// where 0 is the top
+func (s *Stack[T]) Get(i int) T {
+	return s.items[len(s.items)-1-i]
+}
+
+func (s *Stack[T]) Size() int {
+	return len(s.items)
+}
+
+func (s *Stack[T]) IsEmpty() bool {
+	return len(s.items) == 0
+}
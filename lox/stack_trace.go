@@ -0,0 +1,63 @@
+package lox
+
+// callFrame records a call in progress: the name of the callee,
+// and the token of the call expression
+type callFrame struct {
+	name string
+	site Token
+}
+
+// StackFrame is one entry in the traceback of a runtime error,
+// giving the function that was executing and the line it was on
+type StackFrame struct {
+	Function string
+	Source   string
+	Line     int
+}
+
+// Name used for code outside of any function
+const topLevelName = "<script>"
+
+func callableName(callee any) string {
+	switch c := callee.(type) {
+	case *LoxFunction:
+		return functionName(c.decl.name.Lexeme)
+	case *closure:
+		return functionName(c.function.name)
+	case *boundMethod:
+		return functionName(c.method.function.name)
+	case *LoxClass:
+		return c.name
+	case *machineClass:
+		return c.name
+	case *NativeFunction:
+		return c.name
+	}
+	return "<unknown>"
+}
+
+func functionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
+// stackTrace converts the frames of the calls in progress to a
+// traceback ending at token, with the most recent call last
+func stackTrace(frames []callFrame, token Token) []StackFrame {
+	trace := make([]StackFrame, 0, len(frames)+1)
+	function := topLevelName
+	for _, frame := range frames {
+		trace = append(trace, StackFrame{Function: function, Source: sourceName(frame.site), Line: frame.site.Line})
+		function = frame.name
+	}
+	return append(trace, StackFrame{Function: function, Source: sourceName(token), Line: token.Line})
+}
+
+func sourceName(token Token) string {
+	if token.source == nil {
+		return "<unknown>"
+	}
+	return token.source.Name
+}
@@ -0,0 +1,251 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringsModule provides functions on strings. Lengths and indices
+// count characters, i.e. Unicode code points, rather than bytes.
+func stringsModule(h *host) *LoxModule {
+	m := newModule("strings")
+
+	// Functions of one string
+	unary := func(name string, fn func(s string) any) {
+		m.native(name, 1, func(args []any) (any, error) {
+			s, err := stringArg("strings."+name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return fn(s), nil
+		})
+	}
+	// Functions of two strings
+	binary := func(name string, fn func(s string, t string) any) {
+		m.native(name, 2, func(args []any) (any, error) {
+			s, err := stringArg("strings."+name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			t, err := stringArg("strings."+name, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return fn(s, t), nil
+		})
+	}
+
+	unary("len", func(s string) any {
+		return float64(utf8.RuneCountInString(s))
+	})
+	unary("upper", func(s string) any {
+		return strings.ToUpper(s)
+	})
+	unary("lower", func(s string) any {
+		return strings.ToLower(s)
+	})
+	// Removes whitespace from both ends
+	unary("trim", func(s string) any {
+		return strings.TrimSpace(s)
+	})
+	binary("contains", func(s string, t string) any {
+		return strings.Contains(s, t)
+	})
+	binary("startsWith", func(s string, t string) any {
+		return strings.HasPrefix(s, t)
+	})
+	binary("endsWith", func(s string, t string) any {
+		return strings.HasSuffix(s, t)
+	})
+	// Returns -1 if t isn't found
+	binary("indexOf", func(s string, t string) any {
+		idx := strings.Index(s, t)
+		if idx < 0 {
+			return float64(-1)
+		}
+		return float64(utf8.RuneCountInString(s[:idx]))
+	})
+
+	// An empty separator splits s into its characters
+	m.native("split", 2, func(args []any) (any, error) {
+		s, err := stringArg("strings.split", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := stringArg("strings.split", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(s, sep)
+		elements := make([]any, len(parts))
+		for idx, part := range parts {
+			// The list is counted once it's returned, but not
+			// the strings in it
+			if err := h.budget.allocate(len(part)); err != nil {
+				return nil, err
+			}
+			elements[idx] = part
+		}
+		return NewLoxList(elements), nil
+	})
+
+	// Joins the elements of a list, which needn't be strings,
+	// with a separator between each
+	m.native("join", 2, func(args []any) (any, error) {
+		list, err := listArg("strings.join", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := stringArg("strings.join", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		parts := make([]string, len(list.elements))
+		for idx, element := range list.elements {
+			parts[idx] = stringify(element)
+		}
+		return strings.Join(parts, sep), nil
+	})
+
+	// Replaces every occurrence of old
+	m.native("replace", 3, func(args []any) (any, error) {
+		var strs [3]string
+		for idx := range strs {
+			s, err := stringArg("strings.replace", args, idx)
+			if err != nil {
+				return nil, err
+			}
+			strs[idx] = s
+		}
+		s, old, replacement := strs[0], strs[1], strs[2]
+		size := len(s) + strings.Count(s, old)*(len(replacement)-len(old))
+		if err := h.budget.fits(size); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(s, old, replacement), nil
+	})
+
+	m.native("repeat", 2, func(args []any) (any, error) {
+		s, err := stringArg("strings.repeat", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		count, err := intArg("strings.repeat", args, 1)
+		if err != nil || count < 0 {
+			return nil, argError("strings.repeat", 1, "a non-negative integer")
+		}
+		if count > 0 && len(s) > math.MaxInt32/count {
+			return nil, fmt.Errorf("Result of strings.repeat is too long")
+		}
+		if err := h.budget.fits(len(s) * count); err != nil {
+			return nil, err
+		}
+		return strings.Repeat(s, count), nil
+	})
+
+	// Takes the characters from start up to but not including end,
+	// which defaults to the length. As with list slices, negative
+	// indices count back from the end, and both are clamped.
+	m.native("substring", Variadic, func(args []any) (any, error) {
+		if len(args) != 2 && len(args) != 3 {
+			return nil, fmt.Errorf("Expected 2 or 3 arguments but received %d", len(args))
+		}
+		s, err := stringArg("strings.substring", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		chars := []rune(s)
+		start, err := sliceBound("strings.substring", args, 1, len(chars))
+		if err != nil {
+			return nil, err
+		}
+		end := len(chars)
+		if len(args) == 3 {
+			if end, err = sliceBound("strings.substring", args, 2, len(chars)); err != nil {
+				return nil, err
+			}
+		}
+		if start >= end {
+			return "", nil
+		}
+		return string(chars[start:end]), nil
+	})
+
+	// Returns the code point of the character at an index,
+	// which counts back from the end if it is negative
+	m.native("charCodeAt", 2, func(args []any) (any, error) {
+		s, err := stringArg("strings.charCodeAt", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := intArg("strings.charCodeAt", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		chars := []rune(s)
+		if idx < 0 {
+			idx += len(chars)
+		}
+		if idx < 0 || idx >= len(chars) {
+			return nil, fmt.Errorf("Index %v out of range for length %d", args[1], len(chars))
+		}
+		return float64(chars[idx]), nil
+	})
+
+	m.native("fromCharCode", 1, func(args []any) (any, error) {
+		code, err := intArg("strings.fromCharCode", args, 0)
+		if err != nil || code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+			return nil, argError("strings.fromCharCode", 0, "a valid code point")
+		}
+		return string(rune(code)), nil
+	})
+
+	// Replaces each {} in the format string with the next argument,
+	// as print would show it. {{ and }} stand for literal braces.
+	m.native("format", Variadic, func(args []any) (any, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Expected at least 1 argument but received 0")
+		}
+		format, err := stringArg("strings.format", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		values := args[1:]
+		var b strings.Builder
+		for idx := 0; idx < len(format); idx++ {
+			switch rest := format[idx:]; {
+			case strings.HasPrefix(rest, "{}"):
+				if len(values) == 0 {
+					return nil, fmt.Errorf("Too few arguments for the placeholders in the format string")
+				}
+				b.WriteString(stringify(values[0]))
+				values = values[1:]
+				idx++
+			case strings.HasPrefix(rest, "{{"), strings.HasPrefix(rest, "}}"):
+				b.WriteByte(rest[0])
+				idx++
+			default:
+				b.WriteByte(rest[0])
+			}
+		}
+		if len(values) > 0 {
+			return nil, fmt.Errorf("Too many arguments for the placeholders in the format string")
+		}
+		return b.String(), nil
+	})
+
+	return m
+}
+
+// sliceBound converts argument idx of fn to a bound for slicing
+// a sequence of length n, in the same way as toSliceBound. Any
+// integer is accepted, as it is clamped to the sequence.
+func sliceBound(fn string, args []any, idx int, n int) (int, error) {
+	f, ok := args[idx].(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, argError(fn, idx, "an integer")
+	}
+	return clampBound(f, n), nil
+}
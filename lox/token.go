@@ -7,6 +7,12 @@ type Token struct {
 	Lexeme string
 	Literal any
 	Line int
+	// Byte offset and 1-based character column of the
+	// start of the token, and its length in bytes
+	Offset int
+	Column int
+	Length int
+	source *Source
 }
 
 type Repr interface {
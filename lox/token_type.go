@@ -7,6 +7,9 @@ const (
 	RIGHT_PAREN TokenType = iota
 	LEFT_BRACE TokenType = iota
 	RIGHT_BRACE TokenType = iota
+	LEFT_BRACKET TokenType = iota
+	RIGHT_BRACKET TokenType = iota
+	COLON TokenType = iota
 	COMMA TokenType = iota
 	DOT TokenType = iota
 	MINUS TokenType = iota
@@ -20,6 +23,7 @@ const (
 	BANG_EQUAL TokenType = iota
 	EQUAL TokenType = iota
 	EQUAL_EQUAL TokenType = iota
+	ARROW TokenType = iota
 	GREATER TokenType = iota
 	GREATER_EQUAL TokenType = iota
 	LESS TokenType = iota
@@ -32,19 +36,26 @@ const (
 
 	// Keywords. TokenType = iota
 	AND TokenType = iota
+	BREAK TokenType = iota
+	CATCH TokenType = iota
 	CLASS TokenType = iota
+	CONTINUE TokenType = iota
 	ELSE TokenType = iota
 	FALSE TokenType = iota
+	FINALLY TokenType = iota
 	FUN TokenType = iota
 	FOR TokenType = iota
 	IF TokenType = iota
+	IN TokenType = iota
 	NIL TokenType = iota
 	OR TokenType = iota
 	PRINT TokenType = iota
 	RETURN TokenType = iota
 	SUPER TokenType = iota
 	THIS TokenType = iota
+	THROW TokenType = iota
 	TRUE TokenType = iota
+	TRY TokenType = iota
 	VAR TokenType = iota
 	WHILE TokenType = iota
 	EOF TokenType = iota
@@ -1,20 +1,231 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"glox/lox"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+const usage = `Usage: glox [--backend=treewalk|bytecode] [--optimise] [--seed=n] [--dump-bytecode|--dump-ast] [script]
+       glox compile script.lox [--optimise] [-o script.loxc]
+       glox run script.loxc
+`
+
+var backends = map[string]lox.Backend{
+	"treewalk": lox.TreeWalk,
+	"bytecode": lox.Bytecode,
+}
+
+func printUsage() {
+	fmt.Fprint(os.Stderr, usage)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compile":
+			os.Exit(compileCommand(os.Args[2:]))
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		}
+	}
 
-	if len(os.Args) > 2 {
-		fmt.Fprint(os.Stderr, "Usage: glox [script]\n")
+	flag.Usage = printUsage
+	backendName := flag.String("backend", "treewalk", "how to run programs: treewalk or bytecode")
+	optimise := flag.Bool("optimise", false, "fold constants and remove dead code before running")
+	dumpBytecode := flag.Bool("dump-bytecode", false, "print the compiled bytecode of the script instead of running it")
+	dumpAST := flag.Bool("dump-ast", false, "print the syntax tree of the script instead of running it")
+	seed := flag.Int64("seed", 0, "seed math.random with n, to make runs reproducible (default: the time)")
+	flag.Parse()
+
+	backend, ok := backends[*backendName]
+	if !ok {
+		printUsage()
+		os.Exit(64)
+	}
+	// Scripts run from the command line are trusted
+	opts := []lox.Option{lox.WithBackend(backend), lox.WithCapabilities(lox.AllCapabilities), lox.WithStdin(os.Stdin)}
+	if *optimise {
+		opts = append(opts, lox.WithOptimiser())
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			opts = append(opts, lox.WithRandomSeed(*seed))
+		}
+	})
+	vm := lox.NewVM(os.Stdout, os.Stderr, opts...)
+	if args := flag.Args(); len(args) > 1 || *dumpBytecode && *dumpAST {
+		printUsage()
 		os.Exit(64)
-	} else if args := os.Args; len(args) == 2 {
-		lox.RunFile(args[1])
+	} else if len(args) == 1 && *dumpBytecode {
+		os.Exit(dumpFile(args[0], opts))
+	} else if len(args) == 1 && *dumpAST {
+		os.Exit(dumpTree(args[0], opts))
+	} else if len(args) == 1 {
+		os.Exit(runFile(vm, args[0]))
 	} else {
-		lox.RunPrompt()
+		runPrompt(vm)
+	}
+}
+
+// runFile runs the script at path, returning the exit code
+func runFile(vm *lox.VM, path string) int {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := vm.EvalNamed(path, string(bytes)); err != nil {
+		vm.ReportError(err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+// exitCode returns the exit code for an error from running a script
+func exitCode(err error) int {
+	var ds lox.Diagnostics
+	if errors.As(err, &ds) && len(ds) > 0 && ds[0].Code == lox.CodeRuntime {
+		return 70
+	}
+	return 65
+}
+
+// compileCommand implements 'glox compile', which compiles a script to
+// a file that 'glox run' can run without parsing it again
+func compileCommand(args []string) int {
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	flags.Usage = printUsage
+	output := flags.String("o", "", "path of the compiled program (default: the script's path with a .loxc extension)")
+	optimise := flags.Bool("optimise", false, "fold constants and remove dead code before compiling")
+	// Flags can come before or after the script
+	var paths []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return 64
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		paths = append(paths, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(paths) != 1 {
+		printUsage()
+		return 64
+	}
+	path := paths[0]
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + ".loxc"
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var opts []lox.Option
+	if *optimise {
+		opts = append(opts, lox.WithOptimiser())
+	}
+	program, err := lox.Compile(path, string(bytes), opts...)
+	if err != nil {
+		lox.ReportError(os.Stderr, err)
+		return 65
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	_, err = program.WriteTo(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	return 0
 }
 
+// runCommand implements 'glox run', which runs a compiled program
+// with the bytecode backend
+func runCommand(args []string) int {
+	if len(args) != 1 {
+		printUsage()
+		return 64
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	program, err := lox.ReadProgram(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 65
+	}
+
+	vm := lox.NewVM(os.Stdout, os.Stderr, lox.WithBackend(lox.Bytecode), lox.WithCapabilities(lox.AllCapabilities), lox.WithStdin(os.Stdin))
+	if _, err := vm.Run(program); err != nil {
+		vm.ReportError(err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+// dumpFile prints the bytecode compiled from the script at path,
+// returning the exit code
+func dumpFile(path string, opts []lox.Option) int {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	program, err := lox.Compile(path, string(bytes), opts...)
+	if err != nil {
+		lox.ReportError(os.Stderr, err)
+		return 65
+	}
+	program.Disassemble(os.Stdout)
+	return 0
+}
+
+// dumpTree prints the syntax tree of the script at path, as the
+// backend would run it, returning the exit code
+func dumpTree(path string, opts []lox.Option) int {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	tree, err := lox.FormatAST(path, string(bytes), opts...)
+	if err != nil {
+		lox.ReportError(os.Stderr, err)
+		return 65
+	}
+	fmt.Println(tree)
+	return 0
+}
+
+func runPrompt(vm *lox.VM) {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("> ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		// Errors don't end the session
+		if _, err := vm.EvalNamed("<stdin>", line); err != nil {
+			vm.ReportError(err)
+		}
+	}
+}